@@ -0,0 +1,223 @@
+package instrument
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"sync"
+)
+
+// DropPolicy decides what an AsyncSink does with a new event when its queue is full.
+type DropPolicy int
+
+const (
+	// Block waits for room in the queue, applying backpressure to the caller.
+	Block DropPolicy = iota
+	// DropNewest discards the new event.
+	DropNewest
+	// DropOldest discards the oldest queued event to make room for the new one.
+	DropOldest
+)
+
+var errAsyncSinkClosed = errors.New("async sink is closed")
+
+// asyncEvent is a queued event along with the context it was emitted in.
+type asyncEvent struct {
+	ctx  context.Context
+	tags Tags
+}
+
+// AsyncSink hands events to another sink from a background goroutine, so that slow sinks don't stall the caller.
+type AsyncSink struct {
+	in     *Instrument
+	name   string
+	sink   Sink
+	policy DropPolicy
+	queue  chan asyncEvent
+
+	// flushes hands Flush calls to the worker outside the queue, so that the drop policies never have to wait for
+	// room to put one back. The worker closes each channel once the events queued before it have been handled.
+	flushes chan chan struct{}
+
+	queued  *CounterHandle
+	dropped *CounterHandle
+
+	// closing guards sends against the queue being closed.
+	closing sync.RWMutex
+	closed  bool
+	stopped chan struct{}
+}
+
+// NewAsyncSink wraps a sink with a queue of the given size. It panics if the size isn't positive.
+//
+// The name is used for sink error reports and for the instrument.async.<name>.queued and
+// instrument.async.<name>.dropped counters.
+func NewAsyncSink(name string, sink Sink, size int, policy DropPolicy) *AsyncSink {
+	return defaultInstrument.NewAsyncSink(name, sink, size, policy)
+}
+
+// NewAsyncSink wraps a sink for use with the instance, which gets the sink's error reports and counters.
+func (in *Instrument) NewAsyncSink(name string, sink Sink, size int, policy DropPolicy) *AsyncSink {
+	if size <= 0 {
+		panic(fmt.Sprintf("async sink %s needs a queue size above 0, got %d", name, size))
+	}
+
+	as := &AsyncSink{
+		in:      in,
+		name:    name,
+		sink:    sink,
+		policy:  policy,
+		queue:   make(chan asyncEvent, size),
+		flushes: make(chan chan struct{}),
+		queued:  in.NewCounter("instrument.async." + name + ".queued"),
+		dropped: in.NewCounter("instrument.async." + name + ".dropped"),
+		stopped: make(chan struct{}),
+	}
+
+	go as.run()
+
+	return as
+}
+
+// Event queues an event for the wrapped sink, following the drop policy when the queue is full.
+func (as *AsyncSink) Event(ctx context.Context, givenTags Tags) error {
+	// The event outlives the call, so it can't be cancelled with it or share a map with other sinks.
+	event := asyncEvent{
+		ctx:  context.WithoutCancel(ctx),
+		tags: maps.Clone(givenTags),
+	}
+
+	as.closing.RLock()
+	defer as.closing.RUnlock()
+
+	if as.closed {
+		return errAsyncSinkClosed
+	}
+
+	switch as.policy {
+	case DropNewest:
+		if !as.tryEnqueue(event) {
+			as.dropped.Add()
+
+			return nil
+		}
+	case DropOldest:
+		as.enqueueDroppingOldest(event)
+	default:
+		as.queue <- event
+	}
+
+	as.queued.Add()
+
+	return nil
+}
+
+// Flush waits for every event queued before the call to be handled, then flushes the wrapped sink.
+func (as *AsyncSink) Flush() error {
+	as.closing.RLock()
+
+	if !as.closed {
+		flushed := make(chan struct{})
+		as.flushes <- flushed
+		<-flushed
+	}
+
+	as.closing.RUnlock()
+
+	if flusher, ok := as.sink.(Flusher); ok {
+		return flusher.Flush()
+	}
+
+	return nil
+}
+
+// Close drains the queue, stops the worker, and then flushes and closes the wrapped sink. Further events return an
+// error.
+func (as *AsyncSink) Close() error {
+	as.closing.Lock()
+	first := !as.closed
+	if first {
+		as.closed = true
+		close(as.queue)
+	}
+	as.closing.Unlock()
+
+	<-as.stopped
+
+	err := as.Flush()
+
+	// Only the first call closes the wrapped sink, which may not expect to be closed twice.
+	if closer, ok := as.sink.(Closer); ok && first {
+		if closeErr := closer.Close(); closeErr != nil {
+			err = errors.Join(err, fmt.Errorf("could not close sink '%s': %w", as.name, closeErr))
+		}
+	}
+
+	return err
+}
+
+// run passes queued events to the wrapped sink until the queue is closed.
+func (as *AsyncSink) run() {
+	defer close(as.stopped)
+
+	for {
+		select {
+		case event, ok := <-as.queue:
+			if !ok {
+				return
+			}
+
+			as.handle(event)
+		case flushed := <-as.flushes:
+			as.drain(len(as.queue))
+			close(flushed)
+		}
+	}
+}
+
+// drain handles up to the given number of queued events, which covers every event queued before a flush. It stops
+// early if DropOldest has discarded some of them in the meantime.
+func (as *AsyncSink) drain(count int) {
+	for i := 0; i < count; i++ {
+		select {
+		case event, ok := <-as.queue:
+			if !ok {
+				return
+			}
+
+			as.handle(event)
+		default:
+			return
+		}
+	}
+}
+
+// handle passes an event to the wrapped sink.
+func (as *AsyncSink) handle(event asyncEvent) {
+	if err := as.sink.Event(event.ctx, event.tags); err != nil {
+		as.in.reportSinkError(event.ctx, as.name, err)
+	}
+}
+
+// tryEnqueue queues the event if there's room.
+func (as *AsyncSink) tryEnqueue(event asyncEvent) bool {
+	select {
+	case as.queue <- event:
+		return true
+	default:
+		return false
+	}
+}
+
+// enqueueDroppingOldest makes room for the event by discarding the oldest ones. Flushes don't go through the queue,
+// so they're never discarded, and nothing here waits for room.
+func (as *AsyncSink) enqueueDroppingOldest(event asyncEvent) {
+	for !as.tryEnqueue(event) {
+		select {
+		case <-as.queue:
+			as.dropped.Add()
+		default:
+		}
+	}
+}
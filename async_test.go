@@ -0,0 +1,143 @@
+package instrument
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestAsyncSinkDeliversBeforeFlushReturns(t *testing.T) {
+	inner := &memorySink{}
+	as := NewAsyncSink(t.Name(), inner, 4, Block)
+
+	for i := 0; i < 10; i++ {
+		if err := as.Event(context.Background(), Tags{"n": i}); err != nil {
+			t.Fatalf("could not queue event %d: %v", i, err)
+		}
+	}
+
+	if err := as.Flush(); err != nil {
+		t.Fatalf("could not flush: %v", err)
+	}
+
+	if got := len(inner.Events()); got != 10 {
+		t.Errorf("got %d events after Flush, want 10", got)
+	}
+
+	if err := as.Close(); err != nil {
+		t.Fatalf("could not close: %v", err)
+	}
+}
+
+func TestAsyncSinkClosesWrappedSink(t *testing.T) {
+	inner := &memorySink{}
+	as := NewAsyncSink(t.Name(), inner, 4, DropOldest)
+
+	if err := as.Event(context.Background(), Tags{"n": 1}); err != nil {
+		t.Fatalf("could not queue event: %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := as.Close(); err != nil {
+			t.Fatalf("could not close: %v", err)
+		}
+	}
+
+	if got := len(inner.Events()); got != 1 {
+		t.Errorf("got %d events after Close, want 1", got)
+	}
+
+	if got := inner.Closes(); got != 1 {
+		t.Errorf("wrapped sink was closed %d times, want 1", got)
+	}
+
+	if err := as.Event(context.Background(), Tags{"n": 2}); err == nil {
+		t.Error("events after Close should fail")
+	}
+}
+
+func TestAsyncSinkRejectsEmptyQueue(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("a queue size of 0 should panic")
+		}
+	}()
+
+	NewAsyncSink(t.Name(), &memorySink{}, 0, DropOldest)
+}
+
+func TestAsyncSinkUsesOwnInstance(t *testing.T) {
+	in := newTestInstrument(t)
+	as := in.NewAsyncSink(t.Name(), &memorySink{}, 4, DropNewest)
+
+	if err := as.Event(context.Background(), Tags{"n": 1}); err != nil {
+		t.Fatalf("could not queue event: %v", err)
+	}
+
+	if err := as.Close(); err != nil {
+		t.Fatalf("could not close: %v", err)
+	}
+
+	queued := "instrument.async." + t.Name() + ".queued"
+
+	if got := in.NewCounter(queued).Value(); got != 1 {
+		t.Errorf("instance's %s is %d, want 1", queued, got)
+	}
+
+	if _, ok := defaultInstrument.counters.Load(Counter(queued)); ok {
+		t.Errorf("%s was registered with the default instance", queued)
+	}
+}
+
+// gatedSink holds every event until its gate is opened.
+type gatedSink struct {
+	memorySink
+	gate chan struct{}
+}
+
+func (gs *gatedSink) Event(ctx context.Context, givenTags Tags) error {
+	<-gs.gate
+
+	return gs.memorySink.Event(ctx, givenTags)
+}
+
+func TestAsyncSinkDropOldestNeverBlocks(t *testing.T) {
+	inner := &gatedSink{gate: make(chan struct{})}
+	as := NewAsyncSink(t.Name(), inner, 1, DropOldest)
+
+	// The worker is stuck on the first event, and a flush is waiting behind it.
+	if err := as.Event(context.Background(), Tags{"n": -1}); err != nil {
+		t.Fatalf("could not queue event: %v", err)
+	}
+
+	flushed := make(chan error)
+	go func() { flushed <- as.Flush() }()
+
+	produced := make(chan struct{})
+
+	go func() {
+		defer close(produced)
+
+		hammer(func(g int) {
+			for i := 0; i < 100; i++ {
+				_ = as.Event(context.Background(), Tags{"n": g*100 + i})
+			}
+		})
+	}()
+
+	select {
+	case <-produced:
+	case <-time.After(5 * time.Second):
+		t.Fatal("DropOldest producers blocked while a flush was pending")
+	}
+
+	close(inner.gate)
+
+	if err := <-flushed; err != nil {
+		t.Fatalf("could not flush: %v", err)
+	}
+
+	if err := as.Close(); err != nil {
+		t.Fatalf("could not close: %v", err)
+	}
+}
@@ -0,0 +1,196 @@
+package instrument
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"sync"
+	"time"
+)
+
+// Defaults for a BatchConfig left at its zero value.
+const (
+	defaultBatchEvents  = 512
+	defaultBatchLatency = 5 * time.Second
+)
+
+// BatchSink implementers receive events in bulk, for backends that take more than one event per request.
+type BatchSink interface {
+	Events(ctx context.Context, events []Tags) error
+}
+
+// BatchConfig controls when a BatchedSink hands its events over.
+type BatchConfig struct {
+	// MaxEvents sends the batch once it holds this many events. Zero uses a default of 512.
+	MaxEvents int
+
+	// MaxBytes sends the batch before its events grow past this many bytes of JSON. Zero disables the limit.
+	MaxBytes int
+
+	// MaxLatency sends the batch once its oldest event has waited this long. Zero uses a default of five seconds.
+	MaxLatency time.Duration
+}
+
+// BatchedSink collects events and passes them to a BatchSink by count, size, and age.
+type BatchedSink struct {
+	in     *Instrument
+	name   string
+	sink   BatchSink
+	config BatchConfig
+
+	mu    sync.Mutex
+	batch []Tags
+	size  int
+	timer *time.Timer
+
+	// generation counts the batches taken so far, so that a timer which fired for an earlier batch doesn't send the
+	// next one early.
+	generation uint64
+
+	// Batches are sent in the order they were taken, without holding mu: each one takes a ticket under mu, and
+	// waits on turn until serving reaches it.
+	tickets uint64
+	serving uint64
+	turn    *sync.Cond
+}
+
+// NewBatchedSink wraps a BatchSink so that it can be used as a Sink. The name is used for sink error reports from
+// batches that were sent in the background.
+func NewBatchedSink(name string, sink BatchSink, config BatchConfig) *BatchedSink {
+	return defaultInstrument.NewBatchedSink(name, sink, config)
+}
+
+// NewBatchedSink wraps a BatchSink for use with the instance, which gets the error reports from background batches.
+func (in *Instrument) NewBatchedSink(name string, sink BatchSink, config BatchConfig) *BatchedSink {
+	if config.MaxEvents <= 0 {
+		config.MaxEvents = defaultBatchEvents
+	}
+
+	if config.MaxLatency <= 0 {
+		config.MaxLatency = defaultBatchLatency
+	}
+
+	return &BatchedSink{
+		in:     in,
+		name:   name,
+		sink:   sink,
+		config: config,
+		turn:   sync.NewCond(&sync.Mutex{}),
+	}
+}
+
+// Event adds an event to the current batch, sending it if any of the limits were reached.
+func (bs *BatchedSink) Event(ctx context.Context, givenTags Tags) error {
+	size := 0
+	if bs.config.MaxBytes > 0 {
+		size = len(marshalPlain(ctx, givenTags))
+	}
+
+	bs.mu.Lock()
+
+	// Send what we have first if this event would push the batch past the byte limit. The event still goes in the
+	// next batch if that fails.
+	var earlyErr error
+
+	if bs.config.MaxBytes > 0 && len(bs.batch) > 0 && bs.size+size > bs.config.MaxBytes {
+		earlyErr = bs.send(ctx)
+
+		bs.mu.Lock()
+	}
+
+	bs.batch = append(bs.batch, maps.Clone(givenTags))
+	bs.size += size
+
+	if len(bs.batch) == 1 {
+		generation := bs.generation
+		bs.timer = time.AfterFunc(bs.config.MaxLatency, func() { bs.expire(generation) })
+	}
+
+	full := len(bs.batch) >= bs.config.MaxEvents
+	if bs.config.MaxBytes > 0 && bs.size >= bs.config.MaxBytes {
+		full = true
+	}
+
+	if !full {
+		bs.mu.Unlock()
+
+		return earlyErr
+	}
+
+	return errors.Join(earlyErr, bs.send(ctx))
+}
+
+// Flush sends the current batch, if there is one.
+func (bs *BatchedSink) Flush() error {
+	bs.mu.Lock()
+
+	return bs.send(context.Background())
+}
+
+// Close sends the current batch, and then closes the BatchSink if it's a Closer.
+func (bs *BatchedSink) Close() error {
+	err := bs.Flush()
+
+	if closer, ok := bs.sink.(Closer); ok {
+		if closeErr := closer.Close(); closeErr != nil {
+			err = errors.Join(err, fmt.Errorf("could not close sink '%s': %w", bs.name, closeErr))
+		}
+	}
+
+	return err
+}
+
+// expire sends the batch once MaxLatency has passed, unless it was already sent for another reason.
+func (bs *BatchedSink) expire(generation uint64) {
+	bs.mu.Lock()
+
+	if bs.generation != generation {
+		bs.mu.Unlock()
+
+		return
+	}
+
+	if err := bs.send(context.Background()); err != nil {
+		bs.in.reportSinkError(context.Background(), bs.name, err)
+	}
+}
+
+// send takes the current batch and hands it to the BatchSink, after any batches taken before it. The caller must
+// hold mu, which is released as soon as the batch is taken so that new events don't wait on the backend.
+func (bs *BatchedSink) send(ctx context.Context) error {
+	batch := bs.batch
+	bs.batch = nil
+	bs.size = 0
+	bs.generation++
+
+	if bs.timer != nil {
+		bs.timer.Stop()
+		bs.timer = nil
+	}
+
+	ticket := bs.tickets
+	bs.tickets++
+
+	bs.mu.Unlock()
+
+	// Even an empty batch waits its turn, so that Flush returns only once earlier batches are sent.
+	bs.turn.L.Lock()
+	for bs.serving != ticket {
+		bs.turn.Wait()
+	}
+	bs.turn.L.Unlock()
+
+	defer func() {
+		bs.turn.L.Lock()
+		bs.serving++
+		bs.turn.L.Unlock()
+		bs.turn.Broadcast()
+	}()
+
+	if len(batch) == 0 {
+		return nil
+	}
+
+	return bs.sink.Events(ctx, batch)
+}
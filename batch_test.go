@@ -0,0 +1,173 @@
+package instrument
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// blockingBatchSink holds each batch until it's released, and keeps the names of the events it was given in order.
+type blockingBatchSink struct {
+	entered chan struct{}
+	release chan struct{}
+
+	mu    sync.Mutex
+	names []any
+}
+
+func (bb *blockingBatchSink) Events(_ context.Context, events []Tags) error {
+	bb.entered <- struct{}{}
+	<-bb.release
+
+	bb.mu.Lock()
+	defer bb.mu.Unlock()
+
+	for _, event := range events {
+		bb.names = append(bb.names, event["event.name"])
+	}
+
+	return nil
+}
+
+func TestBatchedSinkKeepsEventWhenEarlySendFails(t *testing.T) {
+	inner := &memoryBatchSink{}
+	bs := NewBatchedSink(t.Name(), inner, BatchConfig{MaxBytes: 60, MaxLatency: time.Hour})
+
+	if err := bs.Event(context.Background(), Tags{"event.name": "first"}); err != nil {
+		t.Fatalf("could not add first event: %v", err)
+	}
+
+	// The second event pushes the batch past MaxBytes, so the first is sent on its own, and that fails.
+	inner.fail = true
+
+	err := bs.Event(context.Background(), Tags{"event.name": "second", "padding": "xxxxxxxxxxxxxxxxxxxx"})
+	if !errors.Is(err, errBatchFailed) {
+		t.Fatalf("got error %v, want %v", err, errBatchFailed)
+	}
+
+	if err := bs.Flush(); err != nil {
+		t.Fatalf("could not flush: %v", err)
+	}
+
+	if got := inner.delivered(); got != 1 {
+		t.Errorf("got %d events after the failed send, want the second one", got)
+	}
+}
+
+func TestBatchedSinkClosesWrappedSink(t *testing.T) {
+	inner := &memoryBatchSink{}
+	bs := NewBatchedSink(t.Name(), inner, BatchConfig{MaxLatency: time.Hour})
+
+	if err := bs.Event(context.Background(), Tags{"n": 1}); err != nil {
+		t.Fatalf("could not add event: %v", err)
+	}
+
+	if err := bs.Close(); err != nil {
+		t.Fatalf("could not close: %v", err)
+	}
+
+	if got := inner.delivered(); got != 1 {
+		t.Errorf("got %d events after Close, want 1", got)
+	}
+
+	if inner.closes != 1 {
+		t.Errorf("wrapped sink was closed %d times, want 1", inner.closes)
+	}
+}
+
+func TestBatchedSinkDoesntBlockOnSlowBackend(t *testing.T) {
+	inner := &blockingBatchSink{entered: make(chan struct{}), release: make(chan struct{})}
+	bs := NewBatchedSink(t.Name(), inner, BatchConfig{MaxEvents: 2, MaxLatency: time.Hour})
+	ctx := context.Background()
+	wg := sync.WaitGroup{}
+
+	// Fills a batch, which sends it from a goroutine of its own, as with any caller that fills one.
+	fill := func(first, second string) {
+		if err := bs.Event(ctx, Tags{"event.name": first}); err != nil {
+			t.Errorf("could not add %s: %v", first, err)
+		}
+
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			if err := bs.Event(ctx, Tags{"event.name": second}); err != nil {
+				t.Errorf("could not add %s: %v", second, err)
+			}
+		}()
+	}
+
+	fill("a", "b")
+	<-inner.entered
+
+	// The first batch is stuck in the backend. Filling the second one waits its turn to be sent, but adding to the
+	// third mustn't wait at all.
+	fill("c", "d")
+
+	for taken := false; !taken; time.Sleep(time.Millisecond) {
+		bs.mu.Lock()
+		taken = bs.tickets == 2
+		bs.mu.Unlock()
+	}
+
+	added := make(chan error)
+	go func() { added <- bs.Event(ctx, Tags{"event.name": "e"}) }()
+
+	select {
+	case err := <-added:
+		if err != nil {
+			t.Fatalf("could not add e: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Event blocked while another batch was being sent")
+	}
+
+	go func() {
+		for range inner.entered {
+		}
+	}()
+
+	close(inner.release)
+	wg.Wait()
+
+	if err := bs.Flush(); err != nil {
+		t.Fatalf("could not flush: %v", err)
+	}
+
+	close(inner.entered)
+
+	want := []any{"a", "b", "c", "d", "e"}
+	if got := inner.names; !slices.Equal(got, want) {
+		t.Errorf("got events %v, want them in order %v", got, want)
+	}
+}
+
+func TestBatchedSinkIgnoresStaleTimer(t *testing.T) {
+	inner := &memoryBatchSink{}
+	bs := NewBatchedSink(t.Name(), inner, BatchConfig{MaxLatency: time.Hour})
+
+	if err := bs.Event(context.Background(), Tags{"n": 1}); err != nil {
+		t.Fatalf("could not add event: %v", err)
+	}
+
+	// The first batch's timer fires, but only gets the lock after the batch was sent and a new one was started.
+	stale := bs.generation
+
+	if err := bs.Flush(); err != nil {
+		t.Fatalf("could not flush: %v", err)
+	}
+
+	if err := bs.Event(context.Background(), Tags{"n": 2}); err != nil {
+		t.Fatalf("could not add event: %v", err)
+	}
+
+	bs.expire(stale)
+
+	if got := inner.delivered(); got != 1 {
+		t.Errorf("got %d events after a stale timer fired, want only the first batch's", got)
+	}
+}
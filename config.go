@@ -0,0 +1,159 @@
+package instrument
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// Environment variables read by ConfigFromEnv.
+const (
+	envLevel   = "INSTRUMENT_LEVEL"
+	envSilent  = "INSTRUMENT_SILENT"
+	envNoColor = "NO_COLOR"
+)
+
+// Config changes how events are filtered and shown. Nil fields are left as they are.
+type Config struct {
+	// Level is the least severe level that's emitted.
+	Level *Level
+
+	// Silent turns off the terminal sink.
+	Silent *bool
+
+	// Color forces colorized terminal output on or off. Other lipgloss users in the program aren't affected.
+	Color *bool
+
+	// Format chooses between JSON and the console layout for terminal output.
+	Format *TerminalFormat
+}
+
+// Configure applies the config to the default instance.
+func Configure(config Config) {
+	defaultInstrument.Configure(config)
+}
+
+// Configure applies the config to the instance.
+func (in *Instrument) Configure(config Config) {
+	if config.Level != nil {
+		in.SetLevel(*config.Level)
+	}
+
+	if config.Silent != nil {
+		in.Silence(*config.Silent)
+	}
+
+	if config.Format != nil {
+		in.SetTerminalFormat(*config.Format)
+	}
+
+	if config.Color != nil {
+		in.terminal.SetColor(*config.Color)
+	}
+}
+
+// ConfigFromEnv reads a config from INSTRUMENT_LEVEL, INSTRUMENT_SILENT and NO_COLOR. Unset variables leave their
+// fields nil, and NO_COLOR turns colors off when it's set to anything but an empty string.
+func ConfigFromEnv() (Config, error) {
+	config := Config{}
+
+	if val, ok := os.LookupEnv(envLevel); ok {
+		level, err := ParseLevel(val)
+		if err != nil {
+			return Config{}, fmt.Errorf("could not read %s: %w", envLevel, err)
+		}
+
+		config.Level = &level
+	}
+
+	if val, ok := os.LookupEnv(envSilent); ok {
+		silent, err := strconv.ParseBool(val)
+		if err != nil {
+			return Config{}, fmt.Errorf("could not read %s: %w", envSilent, err)
+		}
+
+		config.Silent = &silent
+	}
+
+	if os.Getenv(envNoColor) != "" {
+		color := false
+		config.Color = &color
+	}
+
+	return config, nil
+}
+
+// RegisterFlags adds the -level, -debug, -trace and -silent flags for the default instance to the flag set. Nothing
+// is registered unless this is called, so programs can use their own flags with the same names instead.
+func RegisterFlags(fs *flag.FlagSet) {
+	defaultInstrument.RegisterFlags(fs)
+}
+
+// RegisterFlags adds the -level, -debug, -trace and -silent flags for the instance to the flag set.
+func (in *Instrument) RegisterFlags(fs *flag.FlagSet) {
+	fs.Var(&levelFlag{in: in}, "level", "Least severe level to log: trace, debug, info, warn, error or fatal.")
+	fs.Var(&boolFlag{get: func() bool { return in.enabled(DEBUG) }, set: in.SetDebug}, "debug", "Enable debug logging.")
+	fs.Var(&boolFlag{get: func() bool { return in.enabled(TRACE) }, set: in.SetTrace}, "trace",
+		"Enable trace logging. EXTREMELY VERBOSE.")
+	fs.Var(&boolFlag{get: func() bool { return in.settings.Load().silent }, set: in.Silence}, "silent",
+		"Silence terminal output from default sink. Will not affect other sinks.")
+}
+
+// boolFlag is a boolean flag that goes through an instance's setters, rather than owning its value.
+type boolFlag struct {
+	get func() bool
+	set func(bool)
+}
+
+// IsBoolFlag lets the flag be given without a value.
+func (bf *boolFlag) IsBoolFlag() bool {
+	return true
+}
+
+// String returns the current value. The flag package also calls it on a zero boolFlag, to find the default.
+func (bf *boolFlag) String() string {
+	if bf == nil || bf.get == nil {
+		return "false"
+	}
+
+	return strconv.FormatBool(bf.get())
+}
+
+// Set parses and applies the value.
+func (bf *boolFlag) Set(val string) error {
+	to, err := strconv.ParseBool(val)
+	if err != nil {
+		return err
+	}
+
+	bf.set(to)
+
+	return nil
+}
+
+// levelFlag sets an instance's level from a flag.
+type levelFlag struct {
+	in *Instrument
+}
+
+// String returns the current level. Like boolFlag, it handles the zero value for the flag package.
+func (lf *levelFlag) String() string {
+	if lf == nil || lf.in == nil {
+		return INFO.String()
+	}
+
+	return lf.in.settings.Load().level.String()
+}
+
+// Set parses and applies the level.
+func (lf *levelFlag) Set(val string) error {
+	level, err := ParseLevel(val)
+	if err != nil {
+		return err
+	}
+
+	lf.in.SetLevel(level)
+
+	return nil
+}
@@ -0,0 +1,175 @@
+package instrument
+
+import (
+	"bytes"
+	"flag"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
+)
+
+// unsetenv removes an environment variable for the length of the test.
+func unsetenv(t *testing.T, key string) {
+	t.Helper()
+
+	t.Setenv(key, "")
+
+	if err := os.Unsetenv(key); err != nil {
+		t.Fatalf("could not unset %s: %v", key, err)
+	}
+}
+
+func TestConfigFromEnv(t *testing.T) {
+	for _, key := range []string{envLevel, envSilent, envNoColor} {
+		unsetenv(t, key)
+	}
+
+	config, err := ConfigFromEnv()
+	if err != nil {
+		t.Fatalf("could not read an empty environment: %v", err)
+	}
+
+	if config.Level != nil || config.Silent != nil || config.Color != nil {
+		t.Errorf("got %+v from an empty environment, want only nil fields", config)
+	}
+
+	// An empty NO_COLOR doesn't count.
+	t.Setenv(envNoColor, "")
+
+	if config, err := ConfigFromEnv(); err != nil || config.Color != nil {
+		t.Errorf("got color %v, %v with an empty NO_COLOR, want nil", config.Color, err)
+	}
+
+	t.Setenv(envLevel, "dbg")
+	t.Setenv(envSilent, "true")
+	t.Setenv(envNoColor, "1")
+
+	config, err = ConfigFromEnv()
+	if err != nil {
+		t.Fatalf("could not read the environment: %v", err)
+	}
+
+	if config.Level == nil || *config.Level != DEBUG {
+		t.Errorf("got level %v, want DBG", config.Level)
+	}
+
+	if config.Silent == nil || !*config.Silent {
+		t.Errorf("got silent %v, want true", config.Silent)
+	}
+
+	if config.Color == nil || *config.Color {
+		t.Errorf("got color %v, want false", config.Color)
+	}
+
+	for key, val := range map[string]string{envLevel: "loud", envSilent: "maybe"} {
+		t.Run(key, func(t *testing.T) {
+			t.Setenv(key, val)
+
+			if _, err := ConfigFromEnv(); err == nil {
+				t.Errorf("%s=%s should fail", key, val)
+			}
+		})
+	}
+}
+
+func TestRegisterFlags(t *testing.T) {
+	for _, tc := range []struct {
+		args   []string
+		level  Level
+		silent bool
+	}{
+		{nil, INFO, false},
+		{[]string{"-level=warn"}, WARN, false},
+		{[]string{"-level", "ERR"}, ERROR, false},
+		{[]string{"-debug"}, DEBUG, false},
+		{[]string{"-trace"}, TRACE, false},
+		{[]string{"-trace", "-debug=false"}, INFO, false},
+		{[]string{"-silent"}, INFO, true},
+	} {
+		in := newTestInstrument(t)
+		in.Silence(false)
+
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		in.RegisterFlags(fs)
+
+		if err := fs.Parse(tc.args); err != nil {
+			t.Errorf("could not parse %q: %v", tc.args, err)
+
+			continue
+		}
+
+		if got := in.settings.Load(); got.level != tc.level || got.silent != tc.silent {
+			t.Errorf("%q left level %s and silent %v, want %s and %v", tc.args, got.level, got.silent, tc.level, tc.silent)
+		}
+	}
+
+	for _, args := range [][]string{{"-level=loud"}, {"-level=metric"}, {"-debug=maybe"}} {
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		fs.SetOutput(io.Discard)
+		newTestInstrument(t).RegisterFlags(fs)
+
+		if err := fs.Parse(args); err == nil {
+			t.Errorf("%q should fail", args)
+		}
+	}
+
+	// The flag package reads defaults from zero values, which mustn't panic.
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(&bytes.Buffer{})
+	newTestInstrument(t).RegisterFlags(fs)
+	fs.PrintDefaults()
+}
+
+func TestConfigureLeavesNilFields(t *testing.T) {
+	in := newTestInstrument(t)
+	in.SetLevel(WARN)
+	in.SetTerminalFormat(TerminalConsole)
+	in.terminal.SetColor(true)
+
+	in.Configure(Config{})
+
+	settings := in.settings.Load()
+	if settings.level != WARN || !settings.silent {
+		t.Errorf("empty config changed the settings to %+v", settings)
+	}
+
+	if got := in.terminal.Format(); got != TerminalConsole {
+		t.Errorf("empty config changed the format to %s", got)
+	}
+
+	if got := in.terminal.Renderer().ColorProfile(); got != termenv.TrueColor {
+		t.Errorf("empty config changed the color profile to %v", got)
+	}
+
+	level, silent, format := ERROR, false, TerminalJSON
+	in.Configure(Config{Level: &level, Silent: &silent, Format: &format})
+
+	settings = in.settings.Load()
+	if settings.level != ERROR || settings.silent || in.terminal.Format() != TerminalJSON {
+		t.Errorf("config didn't apply: got %+v and format %s", settings, in.terminal.Format())
+	}
+
+	if got := in.terminal.Renderer().ColorProfile(); got != termenv.TrueColor {
+		t.Errorf("config without Color changed the color profile to %v", got)
+	}
+}
+
+func TestConfigureColorIsScoped(t *testing.T) {
+	in := newTestInstrument(t)
+	global := lipgloss.ColorProfile()
+
+	for color, want := range map[bool]termenv.Profile{true: termenv.TrueColor, false: termenv.Ascii} {
+		in.Configure(Config{Color: &color})
+
+		if got := in.terminal.Renderer().ColorProfile(); got != want {
+			t.Errorf("Color: %v left the terminal with profile %v, want %v", color, got, want)
+		}
+	}
+
+	if got := lipgloss.ColorProfile(); got != global {
+		t.Errorf("Configure changed lipgloss' global profile from %v to %v", global, got)
+	}
+}
@@ -0,0 +1,188 @@
+package instrument
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// consoleTimeFormat is how ConsoleEncoder shows timestamps. The date is left out, since it rarely changes while
+// someone's watching.
+const consoleTimeFormat = "15:04:05.000"
+
+// consoleIndent is added before the caller for each span that an event is nested in.
+const consoleIndent = "  "
+
+// consoleHidden are the tags that ConsoleEncoder shows in its own way, or leaves out as noise.
+var consoleHidden = map[string]bool{
+	"meta.timestamp":    true,
+	"meta.level":        true,
+	"meta.instance":     true,
+	"meta.caller":       true,
+	"meta.file":         true,
+	"meta.line":         true,
+	"trace.id":          true,
+	"trace.parent":      true,
+	"trace.depth":       true,
+	"trace.start":       true,
+	"trace.duration.ms": true,
+	"metric.name":       true,
+	"metric.value":      true,
+	"metric.labels":     true,
+}
+
+// dimStyle is used for the parts of a console line that give context rather than content.
+var dimStyle = newStyle("#888a85")
+
+// ConsoleEncoder writes events in a layout meant for people rather than machines:
+//
+//	15:04:05.000 INF main.go:42 message key=value
+//
+// Events are indented by how many spans they're nested in, following their trace.depth tag, and finished spans show
+// their name and duration as the message. Files are shown relative to their module's root, and metrics as
+// name{labels}=value.
+type ConsoleEncoder struct{}
+
+// Encode writes the event in the console layout.
+func (ConsoleEncoder) Encode(
+	ctx context.Context, buf *bytes.Buffer, givenTags Tags, renderer *lipgloss.Renderer,
+) error {
+	style := onRenderer(levelStyle(givenTags), renderer)
+	dim := onRenderer(dimStyle, renderer)
+
+	if timestamp, ok := givenTags["meta.timestamp"].(time.Time); ok {
+		buf.WriteString(sprintf(dim, "%s", timestamp.Local().Format(consoleTimeFormat)))
+		buf.WriteByte(' ')
+	}
+
+	level, ok := givenTags["meta.level"].(Level)
+	if !ok {
+		level = INFO
+	}
+
+	buf.WriteString(sprintf(style, "%s", level))
+	buf.WriteByte(' ')
+
+	if level == METRIC {
+		writeConsoleMetric(buf, givenTags, style)
+		writeConsoleTags(ctx, buf, givenTags, nil, style)
+
+		return nil
+	}
+
+	// The depth comes with the event rather than its context, so that it survives hops such as an AsyncSink.
+	depth, _ := givenTags["trace.depth"].(int)
+	buf.WriteString(strings.Repeat(consoleIndent, depth))
+
+	if file, ok := givenTags["meta.file"].(string); ok {
+		buf.WriteString(sprintf(dim, "%s:%v", moduleRelative(file), givenTags["meta.line"]))
+		buf.WriteByte(' ')
+	}
+
+	skip := map[string]bool{}
+
+	if name, ok := givenTags["trace.name"].(string); ok {
+		buf.WriteString(name)
+		skip["trace.name"] = true
+
+		if ms, ok := givenTags["trace.duration.ms"].(int64); ok {
+			buf.WriteString(sprintf(dim, " (%s)", time.Duration(ms)*time.Millisecond))
+		}
+	} else {
+		for _, key := range slogMessageKeys {
+			if msg, ok := givenTags[key].(string); ok {
+				buf.WriteString(msg)
+				skip[key] = true
+
+				break
+			}
+		}
+	}
+
+	writeConsoleTags(ctx, buf, givenTags, skip, style)
+
+	return nil
+}
+
+// writeConsoleMetric writes a metric as name{labels}=value.
+func writeConsoleMetric(buf *bytes.Buffer, givenTags Tags, style *lipgloss.Style) {
+	buf.WriteString(sprintf(style, "%v", givenTags["metric.name"]))
+
+	if labels, ok := givenTags["metric.labels"].(Tags); ok && len(labels) > 0 {
+		buf.WriteByte('{')
+
+		for i, key := range sortedTagKeys(labels) {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+
+			writeLogfmtPair(buf, key, labels[key], nil)
+		}
+
+		buf.WriteByte('}')
+	}
+
+	buf.WriteByte('=')
+	buf.WriteString(logfmtValue(givenTags["metric.value"]))
+}
+
+// writeConsoleTags writes every tag that isn't hidden or skipped as key=value pairs, each after a space.
+func writeConsoleTags(
+	ctx context.Context, buf *bytes.Buffer, givenTags Tags, skip map[string]bool, style *lipgloss.Style,
+) {
+	for _, key := range OrderedKeys(ctx, givenTags) {
+		if consoleHidden[key] || skip[key] {
+			continue
+		}
+
+		buf.WriteByte(' ')
+		writeLogfmtPair(buf, key, givenTags[key], style)
+	}
+}
+
+// moduleRoots caches the module root found for each directory, or an empty string if it isn't in a module.
+var moduleRoots SyncMap[string, string]
+
+// moduleRelative shortens a source file's path to be relative to the root of its Go module. Files outside a module
+// keep their full path.
+func moduleRelative(file string) string {
+	dir := filepath.Dir(file)
+
+	root, ok := moduleRoots.Load(dir)
+	if !ok {
+		root = findModuleRoot(dir)
+		moduleRoots.Store(dir, root)
+	}
+
+	if root == "" {
+		return file
+	}
+
+	rel, err := filepath.Rel(root, file)
+	if err != nil {
+		return file
+	}
+
+	return rel
+}
+
+// findModuleRoot walks up from a directory until it finds a go.mod file.
+func findModuleRoot(dir string) string {
+	for {
+		if info, err := os.Stat(filepath.Join(dir, "go.mod")); err == nil && !info.IsDir() {
+			return dir
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+
+		dir = parent
+	}
+}
@@ -0,0 +1,163 @@
+package instrument
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// encodeConsole encodes one event in the console layout, without colors.
+func encodeConsole(t *testing.T, givenTags Tags) string {
+	t.Helper()
+
+	buf := bytes.Buffer{}
+	renderer := lipgloss.NewRenderer(io.Discard)
+
+	if err := (ConsoleEncoder{}).Encode(context.Background(), &buf, givenTags, renderer); err != nil {
+		t.Fatalf("could not encode: %v", err)
+	}
+
+	return buf.String()
+}
+
+func TestConsoleEncoderLine(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("could not find the working directory: %v", err)
+	}
+
+	at := time.Date(2024, 6, 5, 14, 2, 7, 311_000_000, time.Local)
+
+	got := encodeConsole(t, Tags{
+		"meta.timestamp": at,
+		"meta.level":     WARN,
+		"meta.file":      filepath.Join(wd, "example", "main.go"),
+		"meta.line":      42,
+		"meta.caller":    "main.main",
+		"log.message":    "disk is full",
+		"disk.free":      0,
+	})
+
+	if want := "14:02:07.311 WRN example/main.go:42 disk is full disk.free=0"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestConsoleEncoderSpan(t *testing.T) {
+	got := encodeConsole(t, Tags{
+		"meta.level":        INFO,
+		"trace.name":        "startup",
+		"trace.duration.ms": int64(4),
+		"trace.id":          "not shown",
+		"trace.start":       time.Now(),
+	})
+
+	if want := "INF startup (4ms)"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestConsoleEncoderMetric(t *testing.T) {
+	got := encodeConsole(t, Tags{
+		"meta.level":    METRIC,
+		"metric.name":   "http.requests",
+		"metric.labels": Tags{"status": "200", "method": "GET"},
+		"metric.value":  uint64(17),
+	})
+
+	if want := "MET http.requests{method=GET,status=200}=17"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestConsoleEncoderIndentsNestedEvents(t *testing.T) {
+	in := newTestInstrument(t)
+	buf := bytes.Buffer{}
+
+	// The events go through an AsyncSink, which doesn't pass their context on, so the depth has to come with them.
+	sink := in.NewAsyncSink("console", NewWriterSink(&buf, ConsoleEncoder{}), 16, Block)
+	if err := in.UseSink("console", sink); err != nil {
+		t.Fatalf("could not add sink: %v", err)
+	}
+
+	ctx := WithInstrument(context.Background(), in)
+	Infof(ctx, "top")
+	_ = WithSpan(ctx, "outer", func(ctx context.Context, _ func(Tags)) error {
+		Infof(ctx, "first")
+
+		return WithSpan(ctx, "inner", func(ctx context.Context, _ func(Tags)) error {
+			Infof(ctx, "second")
+
+			return nil
+		})
+	})
+
+	if err := sink.Flush(); err != nil {
+		t.Fatalf("could not flush: %v", err)
+	}
+
+	indents := map[string]int{}
+
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		// Skip the timestamp and level, then count the indentation before the caller.
+		rest := strings.SplitN(line, " ", 3)[2]
+		message := strings.Fields(rest)[1]
+
+		indents[message] = (len(rest) - len(strings.TrimLeft(rest, " "))) / len(consoleIndent)
+	}
+
+	want := map[string]int{"top": 0, "first": 1, "second": 2, "inner": 1, "outer": 0}
+
+	for message, depth := range want {
+		if indents[message] != depth {
+			t.Errorf("%q is indented %d times, want %d:\n%s", message, indents[message], depth, buf.String())
+		}
+	}
+}
+
+func TestModuleRelative(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("could not find the working directory: %v", err)
+	}
+
+	inModule := filepath.Join(wd, "instrumenttest", "instrumenttest.go")
+	if got := moduleRelative(inModule); got != filepath.Join("instrumenttest", "instrumenttest.go") {
+		t.Errorf("got %q for a file in this module", got)
+	}
+
+	module := t.TempDir()
+	if err := os.WriteFile(filepath.Join(module, "go.mod"), []byte("module example.com/m\n"), 0o644); err != nil {
+		t.Fatalf("could not write go.mod: %v", err)
+	}
+
+	nested := filepath.Join(module, "a", "b")
+	if err := os.MkdirAll(nested, 0o755); err != nil {
+		t.Fatalf("could not create %s: %v", nested, err)
+	}
+
+	if got := findModuleRoot(nested); got != module {
+		t.Errorf("got module root %q, want %q", got, module)
+	}
+
+	if got := moduleRelative(filepath.Join(nested, "c.go")); got != filepath.Join("a", "b", "c.go") {
+		t.Errorf("got %q for a nested file", got)
+	}
+
+	// A temporary directory isn't inside any module, so its files keep their full path.
+	outside := filepath.Join(t.TempDir(), "main.go")
+	if root := findModuleRoot(filepath.Dir(outside)); root != "" {
+		t.Skipf("the temporary directory is inside a module at %s", root)
+	}
+
+	if got := moduleRelative(outside); got != outside {
+		t.Errorf("got %q for a file outside any module, want its full path", got)
+	}
+}
@@ -51,3 +51,41 @@ func traceIDFromContext(ctx context.Context) uuid.UUID {
 
 	return typed
 }
+
+// traceRootFromContext returns the outermost span of the current trace for the given context.
+func traceRootFromContext(ctx context.Context) uuid.UUID {
+	val := ctx.Value(keyTraceRoot)
+	if val == nil {
+		return uuid.Nil
+	}
+
+	typed, ok := val.(uuid.UUID)
+	if !ok {
+		return uuid.Nil
+	}
+
+	return typed
+}
+
+// traceDepthFromContext returns how many spans the given context is nested in.
+func traceDepthFromContext(ctx context.Context) int {
+	depth, _ := ctx.Value(keyTraceDepth).(int)
+
+	return depth
+}
+
+// addTraceParent links an event to the span it's emitted in, if any. The span's depth goes along with it, so that
+// sinks which only see the event, such as those behind an AsyncSink, can still tell how deeply it's nested.
+func addTraceParent(ctx context.Context, theseTags Tags) {
+	if traceID := traceIDFromContext(ctx); traceID != uuid.Nil {
+		theseTags["trace.parent"] = traceID
+		theseTags["trace.depth"] = traceDepthFromContext(ctx)
+	}
+}
+
+// orderFromContext returns the keys of the context's tags in the order they were added. It mustn't be changed in place.
+func orderFromContext(ctx context.Context) []string {
+	order, _ := ctx.Value(keyOrder).([]string)
+
+	return order
+}
@@ -0,0 +1,108 @@
+package instrument
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// An Encoder turns an event into a single line of output, without a trailing newline.
+//
+// The context is the one the event was emitted with. The renderer decides which colors can be used, and is nil when
+// the output shouldn't have any.
+type Encoder interface {
+	Encode(ctx context.Context, buf *bytes.Buffer, givenTags Tags, renderer *lipgloss.Renderer) error
+}
+
+// JSONEncoder writes events as JSON objects, with keys in the color of the event's level.
+type JSONEncoder struct{}
+
+// Encode writes the event as JSON.
+func (JSONEncoder) Encode(ctx context.Context, buf *bytes.Buffer, givenTags Tags, renderer *lipgloss.Renderer) error {
+	marshalEvent(ctx, givenTags, buf, colorPalette(levelStyle(givenTags)).on(renderer))
+
+	return nil
+}
+
+// WriterSink encodes events to any io.Writer, one per line.
+//
+// Colors are detected from the writer itself, so a terminal gets them and a pipe or a file doesn't, regardless of
+// where the rest of the program's output goes.
+type WriterSink struct {
+	encoder  Encoder
+	renderer *lipgloss.Renderer
+
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewWriterSink returns a sink that writes to w with the given encoder.
+func NewWriterSink(w io.Writer, encoder Encoder) *WriterSink {
+	return &WriterSink{
+		encoder:  encoder,
+		renderer: lipgloss.NewRenderer(w),
+		w:        w,
+	}
+}
+
+// Renderer returns the renderer used for the writer, to change its color profile.
+func (ws *WriterSink) Renderer() *lipgloss.Renderer {
+	return ws.renderer
+}
+
+// Event writes a line to the writer. Each line is written with a single call, so events don't interleave.
+func (ws *WriterSink) Event(ctx context.Context, givenTags Tags) error {
+	buf := bytes.Buffer{}
+
+	if err := ws.encoder.Encode(ctx, &buf, givenTags, ws.renderer); err != nil {
+		return fmt.Errorf("could not encode event: %w", err)
+	}
+
+	buf.WriteByte('\n')
+
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	if _, err := ws.w.Write(buf.Bytes()); err != nil {
+		return fmt.Errorf("could not write event: %w", err)
+	}
+
+	return nil
+}
+
+// Flush flushes the writer, if it has a Flush method of its own.
+func (ws *WriterSink) Flush() error {
+	flusher, ok := ws.w.(Flusher)
+	if !ok {
+		return nil
+	}
+
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	return flusher.Flush()
+}
+
+// levelStyle returns the style for the event's level, or INFO's if it has none.
+func levelStyle(givenTags Tags) *lipgloss.Style {
+	if level, ok := givenTags["meta.level"].(Level); ok {
+		return level.Style()
+	}
+
+	return INFO.Style()
+}
+
+// onRenderer binds a style to a renderer. A nil style stays nil, and a nil renderer removes the style.
+func onRenderer(style *lipgloss.Style, renderer *lipgloss.Renderer) *lipgloss.Style {
+	if style == nil || renderer == nil {
+		return nil
+	}
+
+	bound := style.Renderer(renderer)
+
+	return &bound
+}
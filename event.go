@@ -7,11 +7,20 @@ import (
 	"time"
 )
 
-const eventCallerSkip = 2
-const eventsEmitted Counter = "instrument.events.total"
+const eventCallerSkip = 3
 
 // PostEvent emits a user-created raw event without contextual metadata.
 func PostEvent(ctx context.Context, name string, givenTags Tags) {
+	FromContext(ctx).postEvent(ctx, name, givenTags)
+}
+
+// PostEvent emits a user-created raw event to the instance without contextual metadata.
+func (in *Instrument) PostEvent(ctx context.Context, name string, givenTags Tags) {
+	in.postEvent(ctx, name, givenTags)
+}
+
+// postEvent adds the caller's metadata to a raw event.
+func (in *Instrument) postEvent(ctx context.Context, name string, givenTags Tags) {
 	caller, filename, line := getCaller(eventCallerSkip)
 
 	if givenTags == nil {
@@ -22,48 +31,101 @@ func PostEvent(ctx context.Context, name string, givenTags Tags) {
 	givenTags["meta.caller"] = caller
 	givenTags["meta.file"] = filename
 	givenTags["meta.line"] = line
-	emit(ctx, givenTags)
+	in.emit(ctx, givenTags)
 }
 
 // emit fans out raw event data to the configured sinks.
-func emit(ctx context.Context, givenTags Tags) {
+func (in *Instrument) emit(ctx context.Context, givenTags Tags) {
 	if givenTags == nil {
 		givenTags = Tags{}
 	}
 
-	givenTags["meta.instance"] = instanceID
-	givenTags["meta.timestamp"] = time.Now()
+	givenTags["meta.instance"] = in.id
+
+	// Events bridged from other loggers may already know when they happened.
+	if _, ok := givenTags["meta.timestamp"]; !ok {
+		givenTags["meta.timestamp"] = time.Now()
+	}
 
 	// Handle debug/trace messages here.
-	if rawLevel, ok := givenTags["meta.level"]; ok {
-		switch rawLevel {
-		case DEBUG:
-			if !*debug {
-				return
-			}
-		case TRACE:
-			if !*trace {
-				return
-			}
+	if level, ok := givenTags["meta.level"].(Level); ok && !in.enabled(level) {
+		return
+	}
+
+	in.eventsEmitted.Add()
+
+	silent := in.settings.Load().silent
+
+	for sinkName, registered := range in.allSinks(ctx) {
+		if silent && registered.sink == Sink(in.terminal) {
+			continue
+		}
+
+		if !registered.accepts(givenTags) {
+			continue
+		}
+
+		if err := registered.sink.Event(ctx, givenTags); err != nil {
+			in.reportSinkError(ctx, sinkName, err)
 		}
 	}
+}
 
-	eventsEmitted.Add()
+// enabled checks if events at the given level are being emitted.
+func (in *Instrument) enabled(level Level) bool {
+	return level >= in.settings.Load().level
+}
+
+// reportSinkError writes a sink failure straight to the terminal, since the failing sink can't be trusted with it.
+func (in *Instrument) reportSinkError(ctx context.Context, sinkName string, err error) {
+	in.reportError(ctx, fmt.Errorf("could not process event sink '%s': %w", sinkName, err))
+}
+
+// reportError writes an error from instrument itself straight to the terminal.
+func (in *Instrument) reportError(ctx context.Context, err error) {
+	if in.settings.Load().silent {
+		return
+	}
+
+	_ = in.terminal.Event(ctx, Tags{
+		"meta.level": ERROR,
+		"error":      err.Error(),
+	})
+}
 
-	for sinkName, sink := range allSinks(ctx) {
-		if err := sink.Event(ctx, givenTags); err != nil {
-			_ = terminal.Event(ctx, Tags{
-				"meta.level": ERROR,
-				"error":      fmt.Sprintf("could not process event sink '%s': %v", sinkName, err),
-			})
+// flushSinks tells every one of the instance's sinks that buffers events to write them out.
+func (in *Instrument) flushSinks() {
+	ctx := WithInstrument(context.Background(), in)
+
+	for sinkName, registered := range in.allSinks(ctx) {
+		flusher, ok := registered.sink.(Flusher)
+		if !ok {
+			continue
+		}
+
+		if err := flusher.Flush(); err != nil {
+			in.reportSinkError(ctx, sinkName, err)
 		}
 	}
 }
 
-// allSinks returns a merged view of global and context-specific sinks for an event.
-func allSinks(ctx context.Context) sinks {
-	s := maps.Clone(globalSinks)
-	maps.Copy(s, sinksFromContext(ctx))
+// allSinks returns a merged view of the instance's and context-specific sinks for an event. It may be the instance's
+// current snapshot, so it mustn't be modified.
+func (in *Instrument) allSinks(ctx context.Context) sinks {
+	global := in.sinks.Load().all
+
+	local, _ := ctx.Value(keyConfiguredSinks).(sinks)
+	if len(local) == 0 {
+		return global
+	}
+
+	s := maps.Clone(global)
+	maps.Copy(s, local)
+
+	// WithoutSink leaves an empty entry to hide a sink.
+	maps.DeleteFunc(s, func(_ string, registered registeredSink) bool {
+		return registered.sink == nil
+	})
 
 	return s
 }
@@ -0,0 +1,449 @@
+package instrument
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// segmentTimeFormat names rotated segments so that they sort in the order they were written.
+const segmentTimeFormat = "2006-01-02T15-04-05.000"
+
+var errFileSinkClosed = errors.New("file sink is closed")
+
+// FileConfig controls where a FileSink writes and how it rotates.
+type FileConfig struct {
+	// Path is the file that events are written to. Rotated segments are kept in the same directory.
+	Path string
+
+	// MaxSize rotates the file before it grows past this many bytes. Zero disables size-based rotation.
+	MaxSize int64
+
+	// Interval rotates the file once it has been open this long. Zero disables time-based rotation.
+	Interval time.Duration
+
+	// Compress gzips rotated segments.
+	Compress bool
+
+	// MaxBackups is the number of rotated segments to keep. Zero keeps all of them.
+	MaxBackups int
+
+	// MaxAge removes rotated segments older than this. Zero keeps them regardless of age.
+	MaxAge time.Duration
+
+	// Encoder formats each line. Nil writes JSON. Files never get colors.
+	Encoder Encoder
+}
+
+// FileSink writes newline-delimited JSON, or another encoding, to a file, rotating it by size and age.
+//
+// Writes are buffered. The buffer is written out whenever Flush is called, which includes the regular metrics flush
+// and Fatalf, and immediately for ERROR and FATAL events.
+type FileSink struct {
+	in     *Instrument
+	config FileConfig
+
+	mu     sync.Mutex
+	file   *os.File
+	buf    *bufio.Writer
+	size   int64
+	opened time.Time
+	closed bool
+
+	// Compression and pruning of rotated segments happen in the background, one rotation after another. This is
+	// closed once the latest rotation's work is done.
+	maintained chan struct{}
+}
+
+// NewFileSink opens the configured file for appending, creating it and its directory if needed.
+func NewFileSink(config FileConfig) (*FileSink, error) {
+	return defaultInstrument.NewFileSink(config)
+}
+
+// NewFileSink opens a file for use with the instance, which gets the error reports from background compression and
+// pruning.
+func (in *Instrument) NewFileSink(config FileConfig) (*FileSink, error) {
+	if config.Path == "" {
+		return nil, errors.New("file sink needs a path")
+	}
+
+	if config.Encoder == nil {
+		config.Encoder = JSONEncoder{}
+	}
+
+	fs := &FileSink{in: in, config: config}
+	if err := fs.open(); err != nil {
+		return nil, err
+	}
+
+	return fs, nil
+}
+
+// Event writes a line to the file, rotating it first if needed.
+func (fs *FileSink) Event(ctx context.Context, givenTags Tags) error {
+	buf := bytes.Buffer{}
+	if err := fs.config.Encoder.Encode(ctx, &buf, givenTags, nil); err != nil {
+		return fmt.Errorf("could not encode event: %w", err)
+	}
+
+	buf.WriteByte('\n')
+	line := buf.Bytes()
+
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	if fs.closed {
+		return errFileSinkClosed
+	}
+
+	// A failed rotation leaves the sink without a file, so try again before giving up on the event.
+	if fs.file == nil {
+		if err := fs.open(); err != nil {
+			return err
+		}
+	}
+
+	var rotateErr error
+
+	if fs.shouldRotate(len(line)) {
+		rotateErr = fs.rotate()
+		if fs.file == nil {
+			return rotateErr
+		}
+	}
+
+	n, err := fs.buf.Write(line)
+	fs.size += int64(n)
+
+	if err != nil {
+		return errors.Join(rotateErr, fmt.Errorf("could not write event: %w", err))
+	}
+
+	if level, ok := givenTags["meta.level"].(Level); ok && (level == ERROR || level == FATAL) {
+		if err := fs.buf.Flush(); err != nil {
+			return errors.Join(rotateErr, fmt.Errorf("could not flush event: %w", err))
+		}
+	}
+
+	return rotateErr
+}
+
+// Flush writes out buffered events and waits for any rotated segments to finish compressing.
+func (fs *FileSink) Flush() error {
+	fs.mu.Lock()
+	err := fs.sync()
+	maintained := fs.maintained
+	fs.mu.Unlock()
+
+	// Wait without the lock, so that events can still be written while a segment is compressed.
+	if maintained != nil {
+		<-maintained
+	}
+
+	return err
+}
+
+// Close flushes and closes the file. Further events return an error.
+func (fs *FileSink) Close() error {
+	err := fs.Flush()
+
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	fs.closed = true
+
+	if fs.file == nil {
+		return err
+	}
+
+	if closeErr := fs.file.Close(); closeErr != nil && err == nil {
+		err = fmt.Errorf("could not close %s: %w", fs.config.Path, closeErr)
+	}
+
+	fs.file = nil
+	fs.buf = nil
+
+	return err
+}
+
+// sync writes the buffer out to disk. The caller must hold the lock.
+func (fs *FileSink) sync() error {
+	if fs.file == nil {
+		return nil
+	}
+
+	if err := fs.buf.Flush(); err != nil {
+		return fmt.Errorf("could not flush %s: %w", fs.config.Path, err)
+	}
+
+	if err := fs.file.Sync(); err != nil {
+		return fmt.Errorf("could not sync %s: %w", fs.config.Path, err)
+	}
+
+	return nil
+}
+
+// open starts appending to the configured path.
+func (fs *FileSink) open() error {
+	if err := os.MkdirAll(filepath.Dir(fs.config.Path), 0o755); err != nil {
+		return fmt.Errorf("could not create log directory: %w", err)
+	}
+
+	file, err := os.OpenFile(fs.config.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("could not open %s: %w", fs.config.Path, err)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+
+		return fmt.Errorf("could not stat %s: %w", fs.config.Path, err)
+	}
+
+	fs.file = file
+	fs.buf = bufio.NewWriter(file)
+	fs.size = info.Size()
+	fs.opened = time.Now()
+
+	return nil
+}
+
+// shouldRotate checks if writing n more bytes would break the size or age limits. Empty files are never rotated.
+func (fs *FileSink) shouldRotate(n int) bool {
+	if fs.size == 0 {
+		return false
+	}
+
+	if fs.config.MaxSize > 0 && fs.size+int64(n) > fs.config.MaxSize {
+		return true
+	}
+
+	return fs.config.Interval > 0 && time.Since(fs.opened) >= fs.config.Interval
+}
+
+// rotate moves the current file aside as a timestamped segment and starts a new one. If that fails, it goes back to
+// appending to the current file, or leaves the sink without a file if even that fails.
+func (fs *FileSink) rotate() error {
+	if err := fs.buf.Flush(); err != nil {
+		return fmt.Errorf("could not flush %s: %w", fs.config.Path, err)
+	}
+
+	if err := fs.file.Close(); err != nil {
+		fs.file, fs.buf = nil, nil
+
+		return fmt.Errorf("could not close %s: %w", fs.config.Path, err)
+	}
+
+	segment := fs.segmentName(time.Now())
+	if err := os.Rename(fs.config.Path, segment); err != nil {
+		return errors.Join(fmt.Errorf("could not rotate %s: %w", fs.config.Path, err), fs.reopen())
+	}
+
+	if err := fs.open(); err != nil {
+		fs.file, fs.buf = nil, nil
+
+		return err
+	}
+
+	previous := fs.maintained
+	done := make(chan struct{})
+	fs.maintained = done
+
+	go func() {
+		defer close(done)
+
+		if previous != nil {
+			<-previous
+		}
+
+		if fs.config.Compress {
+			if err := compressSegment(segment); err != nil {
+				fs.in.reportSinkError(context.Background(), fs.config.Path, err)
+			}
+		}
+
+		if err := fs.prune(); err != nil {
+			fs.in.reportSinkError(context.Background(), fs.config.Path, err)
+		}
+	}()
+
+	return nil
+}
+
+// reopen goes back to appending to the configured path after a failed rotation.
+func (fs *FileSink) reopen() error {
+	if err := fs.open(); err != nil {
+		fs.file, fs.buf = nil, nil
+
+		return err
+	}
+
+	return nil
+}
+
+// segmentName returns an unused path for a segment rotated at the given time, e.g. app-2024-06-05T23-39-00.000.log.
+// Segments rotated within the same millisecond get a counter, e.g. app-2024-06-05T23-39-00.000-1.log.
+func (fs *FileSink) segmentName(at time.Time) string {
+	ext := filepath.Ext(fs.config.Path)
+	stamp := strings.TrimSuffix(fs.config.Path, ext) + "-" + at.UTC().Format(segmentTimeFormat)
+
+	for seq := 0; ; seq++ {
+		name := stamp + ext
+		if seq > 0 {
+			name = stamp + "-" + strconv.Itoa(seq) + ext
+		}
+
+		// An earlier segment with the same name may already be compressed, or be partway through it.
+		if !exists(name) && !exists(name+".gz") && !exists(name+".gz.tmp") {
+			return name
+		}
+	}
+}
+
+// segmentTime returns when a file in the log directory was rotated, and its counter within that millisecond, if it's
+// one of this sink's segments.
+func (fs *FileSink) segmentTime(name string) (time.Time, int, bool) {
+	ext := filepath.Ext(fs.config.Path)
+	prefix := strings.TrimSuffix(filepath.Base(fs.config.Path), ext) + "-"
+
+	name = strings.TrimSuffix(name, ".gz")
+	if !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, ext) {
+		return time.Time{}, 0, false
+	}
+
+	stamp := strings.TrimSuffix(strings.TrimPrefix(name, prefix), ext)
+	if len(stamp) < len(segmentTimeFormat) {
+		return time.Time{}, 0, false
+	}
+
+	at, err := time.Parse(segmentTimeFormat, stamp[:len(segmentTimeFormat)])
+	if err != nil {
+		return time.Time{}, 0, false
+	}
+
+	seq := 0
+
+	if rest := stamp[len(segmentTimeFormat):]; rest != "" {
+		seq, err = strconv.Atoi(strings.TrimPrefix(rest, "-"))
+		if err != nil || !strings.HasPrefix(rest, "-") || seq < 1 {
+			return time.Time{}, 0, false
+		}
+	}
+
+	return at, seq, true
+}
+
+// exists checks if anything is at the path.
+func exists(path string) bool {
+	_, err := os.Lstat(path)
+
+	return err == nil
+}
+
+// prune removes the segments past MaxBackups or MaxAge.
+func (fs *FileSink) prune() error {
+	if fs.config.MaxBackups <= 0 && fs.config.MaxAge <= 0 {
+		return nil
+	}
+
+	dir := filepath.Dir(fs.config.Path)
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("could not list segments: %w", err)
+	}
+
+	type segment struct {
+		path string
+		at   time.Time
+		seq  int
+	}
+
+	segments := []segment{}
+
+	for _, entry := range entries {
+		if at, seq, ok := fs.segmentTime(entry.Name()); ok && !entry.IsDir() {
+			segments = append(segments, segment{path: filepath.Join(dir, entry.Name()), at: at, seq: seq})
+		}
+	}
+
+	// Newest first, so everything past MaxBackups can be dropped.
+	sort.Slice(segments, func(i, j int) bool {
+		if !segments[i].at.Equal(segments[j].at) {
+			return segments[i].at.After(segments[j].at)
+		}
+
+		return segments[i].seq > segments[j].seq
+	})
+
+	var errs []error
+
+	for i, seg := range segments {
+		tooMany := fs.config.MaxBackups > 0 && i >= fs.config.MaxBackups
+		tooOld := fs.config.MaxAge > 0 && time.Since(seg.at) > fs.config.MaxAge
+
+		if tooMany || tooOld {
+			if err := os.Remove(seg.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+				errs = append(errs, fmt.Errorf("could not remove segment: %w", err))
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// compressSegment gzips a rotated segment and removes the original.
+func compressSegment(path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("could not open segment: %w", err)
+	}
+	defer src.Close()
+
+	// Write to a temporary name first, so a half-written archive is never mistaken for a segment.
+	tmp := path + ".gz.tmp"
+
+	dst, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return fmt.Errorf("could not create archive: %w", err)
+	}
+
+	zw := gzip.NewWriter(dst)
+	_, err = io.Copy(zw, src)
+
+	if closeErr := zw.Close(); err == nil {
+		err = closeErr
+	}
+
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		_ = os.Remove(tmp)
+
+		return fmt.Errorf("could not compress segment: %w", err)
+	}
+
+	if err := os.Rename(tmp, path+".gz"); err != nil {
+		return fmt.Errorf("could not finish archive: %w", err)
+	}
+
+	if err := os.Remove(path); err != nil {
+		return fmt.Errorf("could not remove compressed segment: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,145 @@
+package instrument
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// countLines counts the lines in every file in the directory, decompressing gzipped segments.
+func countLines(t *testing.T, dir string) int {
+	t.Helper()
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("could not list %s: %v", dir, err)
+	}
+
+	total := 0
+
+	for _, entry := range entries {
+		file, err := os.Open(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			t.Fatalf("could not open %s: %v", entry.Name(), err)
+		}
+
+		var reader io.Reader = file
+
+		if strings.HasSuffix(entry.Name(), ".gz") {
+			gz, err := gzip.NewReader(file)
+			if err != nil {
+				t.Fatalf("could not decompress %s: %v", entry.Name(), err)
+			}
+
+			reader = gz
+		}
+
+		scanner := bufio.NewScanner(reader)
+		for scanner.Scan() {
+			total++
+		}
+
+		if err := scanner.Err(); err != nil {
+			t.Fatalf("could not read %s: %v", entry.Name(), err)
+		}
+
+		_ = file.Close()
+	}
+
+	return total
+}
+
+func TestFileSinkRotatesWithinMillisecond(t *testing.T) {
+	for _, compress := range []bool{false, true} {
+		t.Run(map[bool]string{false: "plain", true: "compressed"}[compress], func(t *testing.T) {
+			dir := t.TempDir()
+
+			fs, err := NewFileSink(FileConfig{Path: filepath.Join(dir, "events.log"), MaxSize: 200, Compress: compress})
+			if err != nil {
+				t.Fatalf("could not create sink: %v", err)
+			}
+
+			const events = 200
+
+			for i := 0; i < events; i++ {
+				if err := fs.Event(context.Background(), Tags{"event.name": "rotate", "n": i}); err != nil {
+					t.Fatalf("could not write event %d: %v", i, err)
+				}
+			}
+
+			if err := fs.Close(); err != nil {
+				t.Fatalf("could not close sink: %v", err)
+			}
+
+			if got := countLines(t, dir); got != events {
+				t.Errorf("%d of %d events survived rotation", got, events)
+			}
+		})
+	}
+}
+
+func TestFileSinkSegmentNamesAreUnique(t *testing.T) {
+	dir := t.TempDir()
+	fs := &FileSink{config: FileConfig{Path: filepath.Join(dir, "events.log")}}
+	at := time.Date(2024, 6, 5, 23, 39, 0, 0, time.UTC)
+
+	first := fs.segmentName(at)
+	if err := os.WriteFile(first+".gz", nil, 0o644); err != nil {
+		t.Fatalf("could not create segment: %v", err)
+	}
+
+	second := fs.segmentName(at)
+	if second == first {
+		t.Fatalf("segment name %s was reused after it was compressed", second)
+	}
+
+	for _, name := range []string{first, second} {
+		if _, _, ok := fs.segmentTime(filepath.Base(name)); !ok {
+			t.Errorf("segment %s isn't recognized", name)
+		}
+	}
+}
+
+func TestFileSinkRecoversFromFailedRotation(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "logs")
+
+	fs, err := NewFileSink(FileConfig{Path: filepath.Join(dir, "events.log"), MaxSize: 1})
+	if err != nil {
+		t.Fatalf("could not create sink: %v", err)
+	}
+
+	t.Cleanup(func() { _ = fs.Close() })
+
+	if err := fs.Event(context.Background(), Tags{"n": 0}); err != nil {
+		t.Fatalf("could not write first event: %v", err)
+	}
+
+	// Without its directory, the file can't be renamed into a segment.
+	if err := os.RemoveAll(dir); err != nil {
+		t.Fatalf("could not remove %s: %v", dir, err)
+	}
+
+	if err := fs.Event(context.Background(), Tags{"n": 1}); err == nil {
+		t.Fatal("rotating without a directory should fail")
+	}
+
+	for i := 2; i < 4; i++ {
+		if err := fs.Event(context.Background(), Tags{"n": i}); err != nil {
+			t.Fatalf("event %d failed after the rotation did: %v", i, err)
+		}
+	}
+
+	if err := fs.Flush(); err != nil {
+		t.Fatalf("could not flush: %v", err)
+	}
+
+	if got := countLines(t, dir); got < 2 {
+		t.Errorf("only %d events were written after the failed rotation", got)
+	}
+}
@@ -0,0 +1,14 @@
+package instrument
+
+import "testing"
+
+// newTestInstrument returns a silent instance at INFO that's stopped when the test or benchmark finishes.
+func newTestInstrument(tb testing.TB) *Instrument {
+	tb.Helper()
+
+	in := New()
+	in.Silence(true)
+	tb.Cleanup(in.Stop)
+
+	return in
+}
@@ -2,18 +2,19 @@ package instrument
 
 import (
 	"context"
-	"flag"
+	"errors"
+	"fmt"
 	"os"
 	"os/signal"
 	"runtime"
+	"slices"
+	"strings"
 	"syscall"
-
-	"github.com/google/uuid"
 )
 
 type (
 	Tags  map[string]any
-	sinks map[string]Sink
+	sinks map[string]registeredSink
 )
 
 type contextKey int
@@ -23,25 +24,9 @@ const (
 	keyOrder
 	keyConfiguredSinks
 	keyTraceID
-)
-
-var (
-	debug  = flag.Bool("debug", false, "Enable debug logging.")
-	trace  = flag.Bool("trace", false, "Enable trace logging. EXTREMELY VERBOSE.")
-	silent = flag.Bool(
-		"silent",
-		false,
-		"Silence terminal output from default sink. Will not affect other sinks.",
-	)
-
-	// The logging context always includes a random ID to differentiate program runs.
-	instanceID uuid.UUID
-
-	// Always include the terminal sink.
-	terminal    = &TerminalSink{}
-	globalSinks = sinks{
-		"terminal": terminal,
-	}
+	keyTraceRoot
+	keyInstrument
+	keyTraceDepth
 )
 
 // Sink implementers receive events and pass them along to downstream systems.
@@ -49,13 +34,18 @@ type Sink interface {
 	Event(ctx context.Context, t Tags) error
 }
 
-func init() {
-	if id, err := uuid.NewV7(); err != nil {
-		Fatalf(context.Background(), "Could not create a unique ID for this instance: %v", err)
-	} else {
-		instanceID = id
-	}
+// Flusher is implemented by sinks that buffer events. Flush calls it on every global sink.
+type Flusher interface {
+	Flush() error
+}
 
+// Closer is implemented by sinks that hold resources, such as files or connections. Shutdown calls it on every global
+// sink, after flushing it.
+type Closer interface {
+	Close() error
+}
+
+func init() {
 	// Startup a signal handler to switch log levels at runtime.
 	go func() {
 		c := make(chan os.Signal, 1)
@@ -64,37 +54,122 @@ func init() {
 		for {
 			switch <-c {
 			case syscall.SIGHUP:
-				SetDebug(!*debug)
+				SetDebug(!defaultInstrument.enabled(DEBUG))
 			case syscall.SIGUSR1:
-				SetTrace(!*trace)
+				SetTrace(!defaultInstrument.enabled(TRACE))
 			}
 		}
 	}()
 }
 
-// UseSink sets a global sink for all events.
-func UseSink(name string, newSink Sink) {
-	ctx := context.Background()
+// Errors returned when managing sinks by name.
+var (
+	ErrSinkExists   = errors.New("sink already exists")
+	ErrSinkNotFound = errors.New("sink not found")
+)
+
+// UseSink sets a global sink for all events, limited by any options. Sinks that implement MetricSink also receive
+// every metric update. It returns ErrSinkExists if the name is taken.
+func UseSink(name string, newSink Sink, opts ...SinkOption) error {
+	return defaultInstrument.UseSink(name, newSink, opts...)
+}
+
+// UseSink sets a sink for all of the instance's events, limited by any options.
+func (in *Instrument) UseSink(name string, newSink Sink, opts ...SinkOption) error {
+	exists := false
 
-	for sinkName := range allSinks(ctx) {
-		if name == sinkName {
-			Fatalf(ctx, "Cannot override existing '%s' sink!")
+	in.updateSinks(func(next sinks) {
+		if _, exists = next[name]; !exists {
+			next[name] = newRegisteredSink(newSink, opts)
 		}
+	})
+
+	if exists {
+		return fmt.Errorf("could not add sink '%s': %w", name, ErrSinkExists)
 	}
 
-	globalSinks[name] = newSink
+	return nil
 }
 
-// WithSink adds a sink for the given context.
-func WithSink(ctx context.Context, name string, newSink Sink) context.Context {
-	for sinkName := range allSinks(ctx) {
-		if name == sinkName {
-			Fatalf(ctx, "Cannot override existing '%s' sink!")
+// ReplaceSink swaps a global sink for another one with the same name, so that no events are missed in between. The
+// old sink isn't flushed or closed. It returns ErrSinkNotFound if there's no sink with the name.
+func ReplaceSink(name string, newSink Sink, opts ...SinkOption) error {
+	return defaultInstrument.ReplaceSink(name, newSink, opts...)
+}
+
+// ReplaceSink swaps one of the instance's sinks for another one with the same name.
+func (in *Instrument) ReplaceSink(name string, newSink Sink, opts ...SinkOption) error {
+	exists := false
+
+	in.updateSinks(func(next sinks) {
+		if _, exists = next[name]; exists {
+			next[name] = newRegisteredSink(newSink, opts)
 		}
+	})
+
+	if !exists {
+		return fmt.Errorf("could not replace sink '%s': %w", name, ErrSinkNotFound)
 	}
 
+	return nil
+}
+
+// RemoveSink stops sending events to a global sink. The sink isn't flushed or closed. It returns ErrSinkNotFound if
+// there's no sink with the name.
+func RemoveSink(name string) error {
+	return defaultInstrument.RemoveSink(name)
+}
+
+// RemoveSink stops sending the instance's events to a sink.
+func (in *Instrument) RemoveSink(name string) error {
+	exists := false
+
+	in.updateSinks(func(next sinks) {
+		if _, exists = next[name]; exists {
+			delete(next, name)
+		}
+	})
+
+	if !exists {
+		return fmt.Errorf("could not remove sink '%s': %w", name, ErrSinkNotFound)
+	}
+
+	return nil
+}
+
+// Sinks returns the names of the global sinks, sorted.
+func Sinks() []string {
+	return defaultInstrument.Sinks()
+}
+
+// Sinks returns the names of the instance's sinks, sorted.
+func (in *Instrument) Sinks() []string {
+	current := in.sinks.Load().all
+	names := make([]string, 0, len(current))
+
+	for name := range current {
+		names = append(names, name)
+	}
+
+	slices.Sort(names)
+
+	return names
+}
+
+// WithSink adds a sink for the given context, limited by any options. A sink with the same name as a global sink, or
+// one from a parent context, takes its place for this context and its descendants.
+func WithSink(ctx context.Context, name string, newSink Sink, opts ...SinkOption) context.Context {
+	sinks := sinksFromContext(ctx)
+	sinks[name] = newRegisteredSink(newSink, opts)
+
+	return context.WithValue(ctx, keyConfiguredSinks, sinks)
+}
+
+// WithoutSink stops events for the given context and its descendants from going to the named sink, whether it's
+// global or from a parent context. For example, WithoutSink(ctx, "terminal") keeps one request out of the terminal.
+func WithoutSink(ctx context.Context, name string) context.Context {
 	sinks := sinksFromContext(ctx)
-	sinks[name] = newSink
+	sinks[name] = registeredSink{}
 
 	return context.WithValue(ctx, keyConfiguredSinks, sinks)
 }
@@ -104,32 +179,147 @@ func With(ctx context.Context, k string, v any) context.Context {
 	return WithAll(ctx, Tags{k: v})
 }
 
-// WithAll adds multiple tags to the given context.
+// WithAll adds multiple tags to the given context. Tags keep the position they were first added in, and tags added
+// together are ordered by key.
 func WithAll(ctx context.Context, tags Tags) context.Context {
 	parentTags := tagsFromContext(ctx)
+	order := orderFromContext(ctx)
+
+	// Add all the tags, remembering the new ones.
+	for _, k := range sortedTagKeys(tags) {
+		if _, ok := parentTags[k]; !ok {
+			order = append(order, k)
+		}
+
+		parentTags[k] = tags[k]
+	}
+
+	ctx = context.WithValue(ctx, keyTags, parentTags)
+
+	// Clipped, so that appending in a child context never writes into a sibling's order.
+	return context.WithValue(ctx, keyOrder, slices.Clip(order))
+}
+
+// metaKeyOrder is the order of the meta tags that most events have. Any others follow them, sorted.
+var metaKeyOrder = []string{"meta.timestamp", "meta.level", "meta.instance", "meta.caller", "meta.file", "meta.line"}
+
+// OrderedKeys returns the event's keys in a stable order, for sinks that write them out one by one: first the meta
+// tags, then the context's tags in the order they were added with With and WithAll, and then everything else sorted.
+func OrderedKeys(ctx context.Context, givenTags Tags) []string {
+	keys := make([]string, 0, len(givenTags))
+	seen := make(map[string]bool, len(givenTags))
+
+	add := func(key string) {
+		if _, ok := givenTags[key]; ok && !seen[key] {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+	}
+
+	for _, key := range metaKeyOrder {
+		add(key)
+	}
+
+	sorted := sortedTagKeys(givenTags)
+
+	for _, key := range sorted {
+		if strings.HasPrefix(key, "meta.") {
+			add(key)
+		}
+	}
+
+	for _, key := range orderFromContext(ctx) {
+		add(key)
+	}
 
-	// Add all the tags.
-	for k, val := range tags {
-		parentTags[k] = val
+	for _, key := range sorted {
+		add(key)
 	}
 
-	return context.WithValue(ctx, keyTags, parentTags)
+	return keys
 }
 
-// SetDebug sets the visibility of debug events.
+// sortedTagKeys returns the tags' keys in sorted order.
+func sortedTagKeys(givenTags Tags) []string {
+	keys := make([]string, 0, len(givenTags))
+	for key := range givenTags {
+		keys = append(keys, key)
+	}
+
+	slices.Sort(keys)
+
+	return keys
+}
+
+// SetLevel sets the least severe level of events that are emitted. Events without a level are always emitted.
+func SetLevel(level Level) {
+	defaultInstrument.SetLevel(level)
+}
+
+// SetLevel sets the least severe level of the instance's events that are emitted.
+func (in *Instrument) SetLevel(level Level) {
+	in.updateSettings(func(next *settings) {
+		next.level = level
+		next.toggled = false
+	})
+}
+
+// SetDebug sets the visibility of debug events. Turning them on lowers the level to DEBUG, unless it's already lower,
+// and turning them off goes back to the level from before they were turned on, or INFO if that showed them too.
 func SetDebug(to bool) {
-	*debug = to
+	defaultInstrument.SetDebug(to)
 }
 
-// SetTrace sets the visibility of trace and debug events.
+// SetDebug sets the visibility of the instance's debug events, the same way as the package-level SetDebug.
+func (in *Instrument) SetDebug(to bool) {
+	in.updateSettings(func(next *settings) {
+		switch {
+		case to && next.level > DEBUG:
+			next.lower(DEBUG)
+		case !to && next.level <= DEBUG:
+			next.raise()
+		}
+	})
+}
+
+// SetTrace sets the visibility of trace and debug events. Turning them on lowers the level to TRACE, and turning them
+// off goes back to the level from before, the same way as SetDebug.
 func SetTrace(to bool) {
-	*debug = to
-	*trace = to
+	defaultInstrument.SetTrace(to)
+}
+
+// SetTrace sets the visibility of the instance's trace and debug events, the same way as the package-level SetTrace.
+func (in *Instrument) SetTrace(to bool) {
+	in.updateSettings(func(next *settings) {
+		switch {
+		case to:
+			next.lower(TRACE)
+		case next.level <= DEBUG:
+			next.raise()
+		}
+	})
 }
 
 // Silence toggles the default terminal output.
 func Silence(to bool) {
-	*silent = to
+	defaultInstrument.Silence(to)
+}
+
+// Silence toggles the instance's terminal output. Other sinks, including other TerminalSinks, are unaffected.
+func (in *Instrument) Silence(to bool) {
+	in.updateSettings(func(next *settings) {
+		next.silent = to
+	})
+}
+
+// SetTerminalFormat changes how the default terminal output is laid out.
+func SetTerminalFormat(format TerminalFormat) {
+	defaultInstrument.SetTerminalFormat(format)
+}
+
+// SetTerminalFormat changes how the instance's terminal output is laid out.
+func (in *Instrument) SetTerminalFormat(format TerminalFormat) {
+	in.terminal.SetFormat(format)
 }
 
 // getCaller returns information up the stack, used for metadata.
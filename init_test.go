@@ -0,0 +1,144 @@
+package instrument
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestSinkRegistry(t *testing.T) {
+	in := newTestInstrument(t)
+
+	for _, name := range []string{"zeta", "alpha"} {
+		if err := in.UseSink(name, &memorySink{}); err != nil {
+			t.Fatalf("could not add %s: %v", name, err)
+		}
+	}
+
+	if err := in.UseSink("alpha", &memorySink{}); !errors.Is(err, ErrSinkExists) {
+		t.Errorf("adding alpha twice got %v, want %v", err, ErrSinkExists)
+	}
+
+	if got, want := in.Sinks(), []string{"alpha", "terminal", "zeta"}; !slices.Equal(got, want) {
+		t.Errorf("got sinks %v, want %v", got, want)
+	}
+
+	if err := in.ReplaceSink("missing", &memorySink{}); !errors.Is(err, ErrSinkNotFound) {
+		t.Errorf("replacing a missing sink got %v, want %v", err, ErrSinkNotFound)
+	}
+
+	if err := in.RemoveSink("missing"); !errors.Is(err, ErrSinkNotFound) {
+		t.Errorf("removing a missing sink got %v, want %v", err, ErrSinkNotFound)
+	}
+
+	replacement := &memorySink{}
+	if err := in.ReplaceSink("alpha", replacement); err != nil {
+		t.Fatalf("could not replace alpha: %v", err)
+	}
+
+	if err := in.RemoveSink("zeta"); err != nil {
+		t.Fatalf("could not remove zeta: %v", err)
+	}
+
+	Infof(WithInstrument(context.Background(), in), "after the changes")
+
+	if got := len(replacement.Events()); got != 1 {
+		t.Errorf("replacement sink got %d events, want 1", got)
+	}
+
+	if got, want := in.Sinks(), []string{"alpha", "terminal"}; !slices.Equal(got, want) {
+		t.Errorf("got sinks %v after removing zeta, want %v", got, want)
+	}
+}
+
+func TestContextSinks(t *testing.T) {
+	in := newTestInstrument(t)
+	global, shadow, terminal := &memorySink{}, &memorySink{}, &memorySink{}
+
+	if err := in.UseSink("audit", global); err != nil {
+		t.Fatalf("could not add sink: %v", err)
+	}
+
+	// A stand-in for the terminal, so that events that reach it can be counted.
+	if err := in.ReplaceSink("terminal", terminal); err != nil {
+		t.Fatalf("could not replace terminal: %v", err)
+	}
+
+	ctx := WithInstrument(context.Background(), in)
+	shadowed := WithSink(ctx, "audit", shadow)
+	quiet := WithoutSink(ctx, "terminal")
+
+	Infof(ctx, "everywhere")
+	Infof(shadowed, "shadowed")
+	Infof(With(shadowed, "child", true), "shadowed child")
+	Infof(quiet, "quiet")
+	Infof(With(quiet, "child", true), "quiet child")
+
+	messages := func(sink *memorySink) []any {
+		var got []any
+		for _, event := range sink.Events() {
+			got = append(got, event["log.message"])
+		}
+
+		return got
+	}
+
+	if got, want := messages(global), []any{"everywhere", "quiet", "quiet child"}; !slices.Equal(got, want) {
+		t.Errorf("global sink got %v, want %v", got, want)
+	}
+
+	if got, want := messages(shadow), []any{"shadowed", "shadowed child"}; !slices.Equal(got, want) {
+		t.Errorf("context sink got %v, want %v", got, want)
+	}
+
+	// Only the subtree under WithoutSink stays out of the terminal.
+	if got, want := messages(terminal), []any{"everywhere", "shadowed", "shadowed child"}; !slices.Equal(got, want) {
+		t.Errorf("terminal got %v, want %v", got, want)
+	}
+}
+
+func TestOrderedKeys(t *testing.T) {
+	ctx := With(context.Background(), "request", 1)
+	ctx = WithAll(ctx, Tags{"zone": "b", "user": "ada", "attempt": 2})
+	ctx = With(ctx, "request", 3) // Already added, so it keeps its place.
+	ctx = With(ctx, "route", "/")
+
+	event := tagsFromContext(ctx)
+	for _, key := range metaKeyOrder {
+		event[key] = true
+	}
+
+	event["meta.extra"] = true
+	event["log.message"] = "hi"
+	event["error"] = "none"
+
+	want := append(slices.Clone(metaKeyOrder),
+		"meta.extra",
+		"request", "attempt", "user", "zone", "route",
+		"error", "log.message",
+	)
+
+	if got := OrderedKeys(ctx, event); !slices.Equal(got, want) {
+		t.Errorf("got keys\n%v, want\n%v", got, want)
+	}
+}
+
+func TestOrderedKeysSiblingContexts(t *testing.T) {
+	// Three keys at once leave spare capacity in the order slice, which siblings mustn't both append into.
+	parent := WithAll(context.Background(), Tags{"a": 1, "b": 2, "c": 3})
+	first := With(parent, "first", true)
+	second := With(parent, "second", true)
+
+	if got, want := orderFromContext(first), []string{"a", "b", "c", "first"}; !slices.Equal(got, want) {
+		t.Errorf("first sibling has order %v, want %v", got, want)
+	}
+
+	if got, want := orderFromContext(second), []string{"a", "b", "c", "second"}; !slices.Equal(got, want) {
+		t.Errorf("second sibling has order %v, want %v", got, want)
+	}
+
+	if got, want := orderFromContext(parent), []string{"a", "b", "c"}; !slices.Equal(got, want) {
+		t.Errorf("parent has order %v, want %v", got, want)
+	}
+}
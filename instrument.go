@@ -0,0 +1,269 @@
+package instrument
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// An Instrument is a separate telemetry pipeline, with its own sinks, levels, metrics, and instance ID. Its methods
+// work the same way as the package-level functions, which use a default instance.
+//
+// Use one when a library needs telemetry that's kept apart from the program's, or to isolate tests from each other.
+// Bind it to a context with WithInstrument, so that the package-level functions use it for that context.
+type Instrument struct {
+	id uuid.UUID
+
+	// Settings and sinks are replaced whole rather than changed in place, so that emit can read them without locking.
+	settings atomic.Pointer[settings]
+	sinks    atomic.Pointer[sinkSet]
+	terminal *TerminalSink
+
+	counters      SyncMap[Counter, *CounterHandle]
+	gauges        SyncMap[Gauge, *GaugeHandle]
+	inits         SyncMap[any, func()]
+	histograms    SyncMap[string, *Histogram]
+	counterVecs   SyncMap[string, *CounterVec]
+	gaugeVecs     SyncMap[string, *GaugeVec]
+	histogramVecs SyncMap[string, *HistogramVec]
+
+	// Instrument's own metrics.
+	logsTotal      *CounterHandle
+	logsErrors     *CounterHandle
+	logsWarnings   *CounterHandle
+	tracesTotal    *CounterHandle
+	tracesErrors   *CounterHandle
+	eventsEmitted  *CounterHandle
+	labelOverflows *CounterHandle
+	metricsTotal   *GaugeHandle
+
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// defaultInstrument backs the package-level functions, and any context without an instance of its own.
+var defaultInstrument = New()
+
+// New creates an instance that writes to its own terminal sink, with a minimum level of INFO. Its histograms
+// rotate and its metrics flush every minute until Stop is called.
+func New() *Instrument {
+	in := &Instrument{
+		id:       uuid.Must(uuid.NewV7()),
+		terminal: newTerminalSink(os.Stderr),
+		stop:     make(chan struct{}),
+	}
+
+	in.settings.Store(&settings{level: INFO})
+	in.sinks.Store(newSinkSet(sinks{"terminal": {sink: in.terminal}}))
+
+	in.logsTotal = in.NewCounter("instrument.logs.total")
+	in.logsErrors = in.NewCounter("instrument.logs.errors")
+	in.logsWarnings = in.NewCounter("instrument.logs.warnings")
+	in.tracesTotal = in.NewCounter("instrument.traces.total")
+	in.tracesErrors = in.NewCounter("instrument.traces.errors")
+	in.eventsEmitted = in.NewCounter("instrument.events.total")
+	in.labelOverflows = in.NewCounter("instrument.metrics.overflows")
+	in.metricsTotal = in.NewGauge("instrument.metrics.registered")
+
+	go in.tick()
+
+	return in
+}
+
+// ID returns the random ID that's added to every event as meta.instance.
+func (in *Instrument) ID() uuid.UUID {
+	return in.id
+}
+
+// Stop ends the instance's background histogram rotation and metric flushing. It doesn't flush.
+func (in *Instrument) Stop() {
+	in.stopOnce.Do(func() { close(in.stop) })
+}
+
+// Shutdown stops the default instance, as with its Shutdown method.
+func Shutdown(ctx context.Context) error {
+	return defaultInstrument.Shutdown(ctx)
+}
+
+// Shutdown stops background work, emits a final set of metrics, and then flushes and closes each of the instance's
+// sinks, returning any of their errors. Wrappers such as AsyncSink close the sinks they wrap. It gives up when the
+// context ends, leaving any slow sinks to finish on their own. Afterwards, events only go to the instance's terminal
+// sink.
+func (in *Instrument) Shutdown(ctx context.Context) error {
+	in.Stop()
+	in.emitMetrics()
+
+	registered := in.updateSinks(func(next sinks) {
+		clear(next)
+		next["terminal"] = registeredSink{sink: in.terminal}
+	})
+
+	done := make(chan error, 1)
+
+	go func() {
+		done <- closeSinks(registered)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("could not shut down sinks: %w", ctx.Err())
+	}
+}
+
+// closeSinks flushes and then closes each sink, in order of name.
+func closeSinks(toClose sinks) error {
+	names := make([]string, 0, len(toClose))
+	for name := range toClose {
+		names = append(names, name)
+	}
+
+	slices.Sort(names)
+
+	errs := []error{}
+
+	for _, name := range names {
+		sink := toClose[name].sink
+
+		if flusher, ok := sink.(Flusher); ok {
+			if err := flusher.Flush(); err != nil {
+				errs = append(errs, fmt.Errorf("could not flush sink '%s': %w", name, err))
+			}
+		}
+
+		if closer, ok := sink.(Closer); ok {
+			if err := closer.Close(); err != nil {
+				errs = append(errs, fmt.Errorf("could not close sink '%s': %w", name, err))
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// tick rotates histograms and flushes metrics every minute.
+func (in *Instrument) tick() {
+	// TODO: make this configurable at runtime
+	rotate := time.NewTicker(1 * time.Minute)
+	defer rotate.Stop()
+
+	flush := time.NewTicker(1 * time.Minute)
+	defer flush.Stop()
+
+	for {
+		select {
+		case <-rotate.C:
+			in.rotateHistograms()
+		case <-flush.C:
+			in.Flush()
+		case <-in.stop:
+			return
+		}
+	}
+}
+
+// settings are the parts of an instance's configuration that can change while it runs.
+type settings struct {
+	level  Level
+	silent bool
+
+	// toggled is set while SetDebug or SetTrace has lowered the level, which goes back to restore afterwards.
+	toggled bool
+	restore Level
+}
+
+// lower sets the level for SetDebug or SetTrace, remembering the level from before the first of them.
+func (s *settings) lower(level Level) {
+	if !s.toggled {
+		s.toggled = true
+		s.restore = s.level
+	}
+
+	s.level = level
+}
+
+// raise undoes lower. Debug events are being turned off, so a remembered level that shows them becomes INFO.
+func (s *settings) raise() {
+	s.level = INFO
+
+	if s.toggled && s.restore > DEBUG {
+		s.level = s.restore
+	}
+
+	s.toggled = false
+}
+
+// updateSettings replaces the settings with a changed copy. The change may run more than once if the settings are
+// updated concurrently.
+func (in *Instrument) updateSettings(change func(*settings)) {
+	for {
+		current := in.settings.Load()
+		next := *current
+		change(&next)
+
+		if in.settings.CompareAndSwap(current, &next) {
+			return
+		}
+	}
+}
+
+// sinkSet is a snapshot of an instance's sinks, along with the ones that receive metric updates.
+type sinkSet struct {
+	all    sinks
+	metric []MetricSink
+}
+
+// newSinkSet finds the sinks that receive metric updates.
+func newSinkSet(all sinks) *sinkSet {
+	set := &sinkSet{all: all}
+
+	for _, registered := range all {
+		if registered.noMetrics {
+			continue
+		}
+
+		if metricSink, ok := registered.sink.(MetricSink); ok {
+			set.metric = append(set.metric, metricSink)
+		}
+	}
+
+	return set
+}
+
+// updateSinks replaces the sinks with a changed copy, and returns the sinks it replaced. Like updateSettings, the
+// change may run more than once.
+func (in *Instrument) updateSinks(change func(sinks)) sinks {
+	for {
+		current := in.sinks.Load()
+		next := maps.Clone(current.all)
+		change(next)
+
+		if in.sinks.CompareAndSwap(current, newSinkSet(next)) {
+			return current.all
+		}
+	}
+}
+
+// WithInstrument binds an instance to the context, so that the package-level functions use it for the context and
+// its descendants.
+func WithInstrument(ctx context.Context, in *Instrument) context.Context {
+	return context.WithValue(ctx, keyInstrument, in)
+}
+
+// FromContext returns the instance bound to the context, or the default instance.
+func FromContext(ctx context.Context) *Instrument {
+	if in, ok := ctx.Value(keyInstrument).(*Instrument); ok {
+		return in
+	}
+
+	return defaultInstrument
+}
@@ -0,0 +1,84 @@
+package instrument
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// blockingSink's Close waits until the release channel is closed.
+type blockingSink struct {
+	memorySink
+	release chan struct{}
+}
+
+func (bs *blockingSink) Close() error {
+	<-bs.release
+
+	return bs.memorySink.Close()
+}
+
+func TestShutdownClosesWrappedSinks(t *testing.T) {
+	in := newTestInstrument(t)
+	inner, innerBatch := &memorySink{}, &memoryBatchSink{}
+
+	if err := in.UseSink("async", in.NewAsyncSink("async", inner, 4, Block)); err != nil {
+		t.Fatalf("could not add sink: %v", err)
+	}
+
+	if err := in.UseSink("batched", in.NewBatchedSink("batched", innerBatch, BatchConfig{})); err != nil {
+		t.Fatalf("could not add sink: %v", err)
+	}
+
+	Infof(WithInstrument(context.Background(), in), "before shutdown")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := in.Shutdown(ctx); err != nil {
+		t.Fatalf("could not shut down: %v", err)
+	}
+
+	if got := inner.Closes(); got != 1 {
+		t.Errorf("sink wrapped by an AsyncSink was closed %d times, want 1", got)
+	}
+
+	innerBatch.mu.Lock()
+	closes := innerBatch.closes
+	innerBatch.mu.Unlock()
+
+	if closes != 1 {
+		t.Errorf("sink wrapped by a BatchedSink was closed %d times, want 1", closes)
+	}
+
+	if len(inner.Events()) == 0 || innerBatch.delivered() == 0 {
+		t.Error("events weren't flushed to the wrapped sinks before they were closed")
+	}
+}
+
+func TestShutdownRespectsDeadline(t *testing.T) {
+	in := newTestInstrument(t)
+	slow := &blockingSink{release: make(chan struct{})}
+
+	// The sink is closed through a wrapper, so the deadline has to cover wrapped sinks too.
+	if err := in.UseSink("slow", in.NewAsyncSink("slow", slow, 4, Block)); err != nil {
+		t.Fatalf("could not add sink: %v", err)
+	}
+
+	t.Cleanup(func() { close(slow.release) })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	err := in.Shutdown(ctx)
+
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got %v, want a deadline error", err)
+	}
+
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Shutdown took %s with a 50ms deadline", elapsed)
+	}
+}
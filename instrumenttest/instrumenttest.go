@@ -0,0 +1,268 @@
+// Package instrumenttest captures instrument's events so that tests can make assertions about their telemetry.
+package instrumenttest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/gaylatea/instrument"
+)
+
+// UpdateEnv is the environment variable that makes AssertGolden rewrite golden files instead of comparing them.
+const UpdateEnv = "INSTRUMENTTEST_UPDATE"
+
+// volatile replaces the values of VolatileKeys in golden files.
+const volatile = "<volatile>"
+
+// VolatileKeys are the tags that change from run to run, and are masked in golden files.
+var VolatileKeys = []string{
+	"meta.timestamp",
+	"meta.instance",
+	"meta.file",
+	"trace.id",
+	"trace.parent",
+	"trace.start",
+	"trace.duration.ms",
+}
+
+// recorders numbers each Recorder, so that their sink names never collide.
+var recorders atomic.Uint64
+
+// Recorder is a sink that keeps every event for the length of a test.
+//
+// Each Recorder has an instance of its own, so that tests running in parallel don't see each other's events. Only
+// events emitted with its context or its instance are recorded.
+type Recorder struct {
+	t    testing.TB
+	name string
+	in   *instrument.Instrument
+	ctx  context.Context
+
+	mu     sync.Mutex
+	events []instrument.Tags
+}
+
+// New creates a silent instance and starts recording its events. The instance is stopped when the test finishes.
+func New(t testing.TB) *Recorder {
+	t.Helper()
+
+	in := instrument.New()
+	in.Silence(true)
+
+	rec := &Recorder{
+		t:    t,
+		name: fmt.Sprintf("instrumenttest.%d", recorders.Add(1)),
+		in:   in,
+		ctx:  instrument.WithInstrument(context.Background(), in),
+	}
+
+	if err := in.UseSink(rec.name, rec); err != nil {
+		t.Fatalf("could not record events: %v", err)
+	}
+
+	t.Cleanup(in.Stop)
+
+	return rec
+}
+
+// Context returns a context bound to the recorder's instance. Pass it, or a context derived from it, to the code
+// under test.
+func (rec *Recorder) Context() context.Context {
+	return rec.ctx
+}
+
+// Instrument returns the recorder's instance, for code that takes one directly or registers metrics on it.
+func (rec *Recorder) Instrument() *instrument.Instrument {
+	return rec.in
+}
+
+// Event records a copy of the event.
+func (rec *Recorder) Event(_ context.Context, givenTags instrument.Tags) error {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	rec.events = append(rec.events, maps.Clone(givenTags))
+
+	return nil
+}
+
+// Events returns every event recorded so far.
+func (rec *Recorder) Events() []instrument.Tags {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	events := make([]instrument.Tags, len(rec.events))
+	for i, event := range rec.events {
+		events[i] = maps.Clone(event)
+	}
+
+	return events
+}
+
+// Reset forgets every event recorded so far.
+func (rec *Recorder) Reset() {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	rec.events = nil
+}
+
+// AssertLogged checks that a log at the given level contained the substring.
+func (rec *Recorder) AssertLogged(level instrument.Level, substring string) {
+	rec.t.Helper()
+
+	messages := []string{}
+
+	for _, event := range rec.Events() {
+		msg, ok := event["log.message"].(string)
+		if !ok {
+			continue
+		}
+
+		if event["meta.level"] == level && strings.Contains(msg, substring) {
+			return
+		}
+
+		messages = append(messages, fmt.Sprintf("%v %s", event["meta.level"], msg))
+	}
+
+	rec.t.Errorf("no %v log containing %q, got:\n%s", level, substring, strings.Join(messages, "\n"))
+}
+
+// AssertSpan checks that a span with the given name finished, with or without an error.
+func (rec *Recorder) AssertSpan(name string, withError bool) {
+	rec.t.Helper()
+
+	spans := []string{}
+
+	for _, event := range rec.Events() {
+		spanName, ok := event["trace.name"].(string)
+		if !ok {
+			continue
+		}
+
+		failed := event["trace.error"] != nil
+		if spanName == name && failed == withError {
+			return
+		}
+
+		spans = append(spans, fmt.Sprintf("%s (error: %v)", spanName, event["trace.error"]))
+	}
+
+	rec.t.Errorf("no span %q with error=%v, got:\n%s", name, withError, strings.Join(spans, "\n"))
+}
+
+// AssertMetric flushes the recorder's metrics, and checks that the named counter or gauge has the given value. Only
+// metrics registered with the recorder's instance are seen.
+func (rec *Recorder) AssertMetric(name string, value int64) {
+	rec.t.Helper()
+	rec.in.Flush()
+
+	var (
+		found bool
+		last  any
+	)
+
+	for _, event := range rec.Events() {
+		if event["meta.level"] == instrument.METRIC && event["metric.name"] == name {
+			found, last = true, event["metric.value"]
+		}
+	}
+
+	if !found {
+		rec.t.Errorf("metric %q was never flushed", name)
+
+		return
+	}
+
+	if got, ok := metricValue(last); !ok || got != value {
+		rec.t.Errorf("metric %q is %v, want %d", name, last, value)
+	}
+}
+
+// AssertGolden compares every recorded event except metrics against a golden file of JSON lines, with VolatileKeys
+// masked. Setting INSTRUMENTTEST_UPDATE writes the golden file instead.
+func (rec *Recorder) AssertGolden(path string) {
+	rec.t.Helper()
+
+	got := rec.golden()
+
+	if os.Getenv(UpdateEnv) != "" {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			rec.t.Fatalf("could not create golden directory: %v", err)
+		}
+
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			rec.t.Fatalf("could not write golden file: %v", err)
+		}
+
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		rec.t.Fatalf("could not read golden file (set %s=1 to create it): %v", UpdateEnv, err)
+	}
+
+	if !bytes.Equal(got, want) {
+		rec.t.Errorf("events don't match %s:\ngot:\n%s\nwant:\n%s", path, got, want)
+	}
+}
+
+// golden renders the recorded events as normalized JSON lines.
+func (rec *Recorder) golden() []byte {
+	buf := bytes.Buffer{}
+
+	for _, event := range rec.Events() {
+		if event["meta.level"] == instrument.METRIC {
+			continue
+		}
+
+		for key, val := range event {
+			switch typed := val.(type) {
+			case instrument.Level:
+				event[key] = typed.String()
+			case error:
+				event[key] = typed.Error()
+			}
+		}
+
+		for _, key := range VolatileKeys {
+			if _, ok := event[key]; ok {
+				event[key] = volatile
+			}
+		}
+
+		// encoding/json sorts map keys, so the output is stable.
+		line, err := json.Marshal(event)
+		if err != nil {
+			rec.t.Fatalf("could not encode event: %v", err)
+		}
+
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+
+	return buf.Bytes()
+}
+
+// metricValue converts a counter or gauge value for comparison.
+func metricValue(val any) (int64, bool) {
+	switch typed := val.(type) {
+	case int64:
+		return typed, true
+	case uint64:
+		return int64(typed), true
+	default:
+		return 0, false
+	}
+}
@@ -0,0 +1,121 @@
+package instrumenttest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/gaylatea/instrument"
+)
+
+// failures records assertion failures instead of failing the test.
+type failures struct {
+	testing.TB
+	errors []string
+}
+
+func (f *failures) Helper() {}
+
+func (f *failures) Errorf(format string, args ...any) {
+	f.errors = append(f.errors, fmt.Sprintf(format, args...))
+}
+
+func TestRecordersAreIsolated(t *testing.T) {
+	t.Parallel()
+
+	first, second := New(t), New(t)
+
+	instrument.Infof(first.Context(), "for the first recorder")
+	instrument.Infof(context.Background(), "for the default instance")
+
+	first.AssertLogged(instrument.INFO, "for the first recorder")
+
+	for _, event := range second.Events() {
+		if event["log.message"] != nil {
+			t.Errorf("second recorder got %v", event)
+		}
+	}
+
+	for _, event := range first.Events() {
+		if event["log.message"] == "for the default instance" {
+			t.Error("recorder got an event from the default instance")
+		}
+	}
+}
+
+func TestAssertions(t *testing.T) {
+	t.Parallel()
+
+	rec := New(t)
+	ctx := rec.Context()
+
+	instrument.Warnf(ctx, "disk is %d%% full", 91)
+	_ = instrument.WithSpan(ctx, "load", func(context.Context, func(instrument.Tags)) error {
+		return errors.New("not found")
+	})
+	rec.Instrument().NewCounter("test.loads").AddN(2)
+
+	rec.AssertLogged(instrument.WARN, "91% full")
+	rec.AssertSpan("load", true)
+	rec.AssertMetric("test.loads", 2)
+
+	failed := &failures{TB: t}
+	rec.t = failed
+
+	rec.AssertLogged(instrument.ERROR, "91% full")
+	rec.AssertSpan("load", false)
+	rec.AssertMetric("test.loads", 3)
+	rec.AssertMetric("test.missing", 0)
+
+	if len(failed.errors) != 4 {
+		t.Errorf("got %d failures, want 4: %q", len(failed.errors), failed.errors)
+	}
+}
+
+func TestReset(t *testing.T) {
+	t.Parallel()
+
+	rec := New(t)
+	instrument.Infof(rec.Context(), "before")
+	rec.Reset()
+
+	if events := rec.Events(); len(events) != 0 {
+		t.Errorf("got %d events after Reset, want none", len(events))
+	}
+}
+
+func TestAssertGolden(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "golden.jsonl")
+
+	record := func() *Recorder {
+		rec := New(t)
+		ctx := instrument.With(rec.Context(), "user", "ada")
+
+		instrument.Infof(ctx, "signed in")
+		_ = instrument.WithSpan(ctx, "session", func(context.Context, func(instrument.Tags)) error {
+			return nil
+		})
+		rec.Instrument().Flush()
+
+		return rec
+	}
+
+	t.Setenv(UpdateEnv, "1")
+	record().AssertGolden(path)
+
+	t.Setenv(UpdateEnv, "")
+	record().AssertGolden(path)
+
+	changed := record()
+	instrument.Infof(changed.Context(), "signed out")
+
+	failed := &failures{TB: t}
+	changed.t = failed
+	changed.AssertGolden(path)
+
+	if len(failed.errors) != 1 {
+		t.Errorf("got %d failures for a changed event stream, want 1", len(failed.errors))
+	}
+}
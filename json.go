@@ -2,14 +2,19 @@ package instrument
 
 import (
 	"bytes"
+	"context"
+	"encoding"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"math"
 	"reflect"
+	"slices"
 	"strconv"
 	"time"
+	"unicode/utf8"
 
 	"github.com/charmbracelet/lipgloss"
-	"github.com/muesli/termenv"
 )
 
 const (
@@ -34,45 +39,84 @@ var (
 	nullColor   = newStyle("#ad7fa8")
 )
 
-// The default color profile determined by lipgloss, used by ResetColor.
-var defaultProfile = lipgloss.ColorProfile()
-
-// Colorize forces colorized output, even without a TTY.
+// Colorize forces colorized output from the default instance's terminal sink, even without a TTY.
 func Colorize() {
-	lipgloss.SetColorProfile(termenv.TrueColor)
+	defaultInstrument.terminal.SetColor(true)
 }
 
-// ResetColor returns to lipgloss' default color handling.
+// ResetColor returns the default instance's terminal sink to the colors detected from stderr.
 func ResetColor() {
-	lipgloss.SetColorProfile(defaultProfile)
+	defaultInstrument.terminal.ResetColor()
 }
 
-// sprintf writes a string in the given color.
+// sprintf writes a string in the given color. A nil style writes plain text.
 func sprintf(c *lipgloss.Style, format string, args ...interface{}) string {
+	if c == nil {
+		return fmt.Sprintf(format, args...)
+	}
+
 	return c.Render(fmt.Sprintf(format, args...))
 }
 
-// marshal emits colorized JSON output. Adapted from TylerBrock/colorjson with instrument-specific modifications:
+// palette holds the styles used for each kind of JSON token. The zero palette writes plain JSON.
+type palette struct {
+	key, str, boolean, number, null *lipgloss.Style
+}
+
+// colorPalette returns the default jq-like palette, with keys in the given color.
+func colorPalette(keyColor *lipgloss.Style) palette {
+	return palette{
+		key:     keyColor,
+		str:     stringColor,
+		boolean: boolColor,
+		number:  numberColor,
+		null:    nullColor,
+	}
+}
+
+// on binds the palette to a renderer, which decides whether and how its colors are written. A nil renderer returns
+// the zero palette.
+func (p palette) on(renderer *lipgloss.Renderer) palette {
+	return palette{
+		key:     onRenderer(p.key, renderer),
+		str:     onRenderer(p.str, renderer),
+		boolean: onRenderer(p.boolean, renderer),
+		number:  onRenderer(p.number, renderer),
+		null:    onRenderer(p.null, renderer),
+	}
+}
+
+// marshalPlain emits JSON output without any colors, for sinks that don't write to a terminal.
+//
+// The JSON writer was adapted from TylerBrock/colorjson with instrument-specific modifications:
 //   - Uses lipgloss rather than fatih/color.
 //   - The caller determines the key color, for example based on log level.
 //   - No newlines in the output.
 //   - No indentation.
 //   - No max string length.
-//   - No sorting map keys.
+//   - Events' keys are in the order from OrderedKeys, and other maps' keys are sorted.
 //   - No input validation.
-//   - Handles more built-in types.
-func marshal(jsonObj Tags, keyColor *lipgloss.Style) []byte {
+//   - Handles any type that encoding/json does, and more besides.
+func marshalPlain(ctx context.Context, jsonObj Tags) []byte {
 	buffer := bytes.Buffer{}
-	marshalValue(jsonObj, &buffer, keyColor)
+	marshalEvent(ctx, jsonObj, &buffer, palette{})
 
 	return buffer.Bytes()
 }
 
-// marshalMap writes a JSON map.
-func marshalMap(input map[string]interface{}, buf *bytes.Buffer, keyColor *lipgloss.Style) {
-	remaining := len(input)
+// marshalEvent writes an event as a JSON map, with its keys in the order from OrderedKeys.
+func marshalEvent(ctx context.Context, givenTags Tags, buf *bytes.Buffer, colors palette) {
+	marshalObject(OrderedKeys(ctx, givenTags), givenTags, buf, colors)
+}
 
-	if remaining == 0 {
+// marshalMap writes a JSON map, with its keys sorted.
+func marshalMap(input map[string]interface{}, buf *bytes.Buffer, colors palette) {
+	marshalObject(sortedTagKeys(input), input, buf, colors)
+}
+
+// marshalObject writes a JSON map with its keys in the given order.
+func marshalObject(keys []string, input map[string]interface{}, buf *bytes.Buffer, colors palette) {
+	if len(keys) == 0 {
 		buf.WriteString(emptyMap)
 
 		return
@@ -80,12 +124,11 @@ func marshalMap(input map[string]interface{}, buf *bytes.Buffer, keyColor *lipgl
 
 	buf.WriteString(startMap)
 
-	for key, val := range input {
-		buf.WriteString(sprintf(keyColor, "\"%s\": ", key))
-		marshalValue(val, buf, keyColor)
+	for i, key := range keys {
+		marshalKey(key, buf, colors)
+		marshalValue(input[key], buf, colors)
 
-		remaining--
-		if remaining != 0 {
+		if i < len(keys)-1 {
 			buf.WriteString(valueSep)
 		}
 	}
@@ -94,7 +137,7 @@ func marshalMap(input map[string]interface{}, buf *bytes.Buffer, keyColor *lipgl
 }
 
 // marshalArray writes a JSON array.
-func marshalArray(input []interface{}, buf *bytes.Buffer, keyColor *lipgloss.Style) {
+func marshalArray(input []interface{}, buf *bytes.Buffer, colors palette) {
 	if len(input) == 0 {
 		buf.WriteString(emptyArray)
 
@@ -104,7 +147,7 @@ func marshalArray(input []interface{}, buf *bytes.Buffer, keyColor *lipgloss.Sty
 	buf.WriteString(startArray)
 
 	for i, v := range input {
-		marshalValue(v, buf, keyColor)
+		marshalValue(v, buf, colors)
 
 		if i < len(input)-1 {
 			buf.WriteString(valueSep)
@@ -114,44 +157,290 @@ func marshalArray(input []interface{}, buf *bytes.Buffer, keyColor *lipgloss.Sty
 	buf.WriteString(endArray)
 }
 
-// marshalValue handles a bunch of different built-in types.
+// marshalValue handles a bunch of different built-in types, and falls back to reflection for everything else.
 //
 //nolint:cyclop
-func marshalValue(input interface{}, buf *bytes.Buffer, keyColor *lipgloss.Style) {
+func marshalValue(input interface{}, buf *bytes.Buffer, colors palette) {
+	// Typed nil pointers would otherwise panic in the interface cases below.
+	if rv := reflect.ValueOf(input); rv.Kind() == reflect.Pointer && rv.IsNil() {
+		buf.WriteString(sprintf(colors.null, null))
+
+		return
+	}
+
 	switch val := input.(type) {
 	case Tags:
-		marshalMap(val, buf, keyColor)
+		marshalMap(val, buf, colors)
 	case map[string]interface{}:
-		marshalMap(val, buf, keyColor)
+		marshalMap(val, buf, colors)
 	case []interface{}:
-		marshalArray(val, buf, keyColor)
+		marshalArray(val, buf, colors)
 	case string:
-		marshalString(val, buf)
+		marshalString(val, buf, colors)
 	case int, int8, int16, int32, int64:
 		i := reflect.ValueOf(val).Int()
-		buf.WriteString(sprintf(numberColor, "%d", i))
+		buf.WriteString(sprintf(colors.number, "%d", i))
 	case uint, uint8, uint16, uint32, uint64:
 		i := reflect.ValueOf(val).Uint()
-		buf.WriteString(sprintf(numberColor, "%d", i))
-	case float32, float64:
-		f := reflect.ValueOf(val).Float()
-		buf.WriteString(sprintf(numberColor, strconv.FormatFloat(f, 'f', -1, 64)))
+		buf.WriteString(sprintf(colors.number, "%d", i))
+	case float32:
+		marshalFloat(float64(val), 32, buf, colors)
+	case float64:
+		marshalFloat(val, 64, buf, colors)
 	case bool:
-		buf.WriteString(sprintf(boolColor, (strconv.FormatBool(val))))
+		buf.WriteString(sprintf(colors.boolean, (strconv.FormatBool(val))))
 	case nil:
-		buf.WriteString(sprintf(nullColor, null))
+		buf.WriteString(sprintf(colors.null, null))
 	case json.Number:
-		buf.WriteString(sprintf(numberColor, val.String()))
+		if _, err := strconv.ParseFloat(val.String(), 64); err != nil {
+			marshalString(val.String(), buf, colors)
+		} else {
+			buf.WriteString(sprintf(colors.number, "%s", val.String()))
+		}
 	case error:
-		buf.WriteString(sprintf(stringColor, "\"%s\"", val.Error()))
+		marshalString(val.Error(), buf, colors)
 	case time.Time:
-		buf.WriteString(sprintf(stringColor, "\"%s\"", val.UTC().Format(time.RFC3339)))
+		marshalString(val.UTC().Format(time.RFC3339), buf, colors)
+	case json.Marshaler:
+		marshalViaJSON(val, buf, colors)
+	case encoding.TextMarshaler:
+		text, err := val.MarshalText()
+		if err != nil {
+			marshalString(err.Error(), buf, colors)
+		} else {
+			marshalString(string(text), buf, colors)
+		}
 	case fmt.Stringer:
-		buf.WriteString(sprintf(stringColor, "\"%s\"", val.String()))
+		marshalString(val.String(), buf, colors)
+	default:
+		marshalReflect(reflect.ValueOf(val), buf, colors)
+	}
+}
+
+// marshalReflect writes the types that don't have a case of their own, such as structs, pointers, typed slices and
+// maps, and named basic types.
+//
+//nolint:cyclop
+func marshalReflect(val reflect.Value, buf *bytes.Buffer, colors palette) {
+	switch val.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if val.IsNil() {
+			buf.WriteString(sprintf(colors.null, null))
+		} else {
+			marshalValue(val.Elem().Interface(), buf, colors)
+		}
+	case reflect.Slice, reflect.Array:
+		if val.Kind() == reflect.Slice && val.IsNil() {
+			buf.WriteString(sprintf(colors.null, null))
+
+			return
+		}
+
+		// Byte slices are base64-encoded, like encoding/json does.
+		if val.Type().Elem().Kind() == reflect.Uint8 && val.Kind() == reflect.Slice {
+			marshalString(base64.StdEncoding.EncodeToString(val.Bytes()), buf, colors)
+
+			return
+		}
+
+		items := make([]interface{}, val.Len())
+		for i := range items {
+			items[i] = val.Index(i).Interface()
+		}
+
+		marshalArray(items, buf, colors)
+	case reflect.Map:
+		if val.IsNil() {
+			buf.WriteString(sprintf(colors.null, null))
+
+			return
+		}
+
+		marshalReflectMap(val, buf, colors)
+	case reflect.Struct:
+		marshalViaJSON(val.Interface(), buf, colors)
+	case reflect.String:
+		marshalString(val.String(), buf, colors)
+	case reflect.Bool:
+		buf.WriteString(sprintf(colors.boolean, strconv.FormatBool(val.Bool())))
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		buf.WriteString(sprintf(colors.number, "%d", val.Int()))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		buf.WriteString(sprintf(colors.number, "%d", val.Uint()))
+	case reflect.Float32:
+		marshalFloat(val.Float(), 32, buf, colors)
+	case reflect.Float64:
+		marshalFloat(val.Float(), 64, buf, colors)
+	case reflect.Complex64, reflect.Complex128:
+		marshalString(fmt.Sprint(val.Complex()), buf, colors)
+	default:
+		// Channels, functions and unsafe pointers have no meaningful JSON form.
+		buf.WriteString(sprintf(colors.null, null))
 	}
 }
 
+// marshalReflectMap writes a map of any type, with its keys converted to strings and sorted.
+func marshalReflectMap(val reflect.Value, buf *bytes.Buffer, colors palette) {
+	type entry struct {
+		key string
+		val interface{}
+	}
+
+	entries := make([]entry, 0, val.Len())
+	iter := val.MapRange()
+
+	for iter.Next() {
+		entries = append(entries, entry{key: mapKey(iter.Key()), val: iter.Value().Interface()})
+	}
+
+	if len(entries) == 0 {
+		buf.WriteString(emptyMap)
+
+		return
+	}
+
+	slices.SortFunc(entries, func(a, b entry) int {
+		switch {
+		case a.key < b.key:
+			return -1
+		case a.key > b.key:
+			return 1
+		default:
+			return 0
+		}
+	})
+
+	buf.WriteString(startMap)
+
+	for i, e := range entries {
+		marshalKey(e.key, buf, colors)
+		marshalValue(e.val, buf, colors)
+
+		if i < len(entries)-1 {
+			buf.WriteString(valueSep)
+		}
+	}
+
+	buf.WriteString(endMap)
+}
+
+// mapKey converts a map key to a string the same way encoding/json does, falling back to fmt for other types.
+func mapKey(key reflect.Value) string {
+	if key.Kind() == reflect.String {
+		return key.String()
+	}
+
+	if marshaler, ok := key.Interface().(encoding.TextMarshaler); ok {
+		if text, err := marshaler.MarshalText(); err == nil {
+			return string(text)
+		}
+	}
+
+	switch key.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(key.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(key.Uint(), 10)
+	default:
+		return fmt.Sprint(key.Interface())
+	}
+}
+
+// marshalViaJSON lets encoding/json handle a value, then decodes the result so that it's written with colors.
+func marshalViaJSON(input interface{}, buf *bytes.Buffer, colors palette) {
+	raw, err := json.Marshal(input)
+	if err != nil {
+		marshalString(fmt.Sprintf("%+v", input), buf, colors)
+
+		return
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+
+	var decoded interface{}
+	if err := decoder.Decode(&decoded); err != nil {
+		marshalString(string(raw), buf, colors)
+
+		return
+	}
+
+	marshalValue(decoded, buf, colors)
+}
+
+// marshalFloat writes a JSON number. JSON has no NaN or infinities, so those are written as strings.
+func marshalFloat(f float64, bitSize int, buf *bytes.Buffer, colors palette) {
+	switch {
+	case math.IsNaN(f):
+		marshalString("NaN", buf, colors)
+	case math.IsInf(f, 1):
+		marshalString("+Inf", buf, colors)
+	case math.IsInf(f, -1):
+		marshalString("-Inf", buf, colors)
+	default:
+		// Like encoding/json, switch to exponents for very large and very small numbers.
+		format := byte('f')
+		if abs := math.Abs(f); abs != 0 && (abs < 1e-6 || abs >= 1e21) {
+			format = 'e'
+		}
+
+		buf.WriteString(sprintf(colors.number, "%s", strconv.FormatFloat(f, format, -1, bitSize)))
+	}
+}
+
+// marshalKey writes a JSON object key and its separator.
+func marshalKey(key string, buf *bytes.Buffer, colors palette) {
+	buf.WriteString(sprintf(colors.key, "%s: ", quote(key)))
+}
+
 // marshalString writes a JSON string.
-func marshalString(str string, buf *bytes.Buffer) {
-	buf.WriteString(sprintf(stringColor, "\"%s\"", str))
+func marshalString(str string, buf *bytes.Buffer, colors palette) {
+	buf.WriteString(sprintf(colors.str, "%s", quote(str)))
+}
+
+// quote returns a JSON string literal, escaped following RFC 8259. Invalid UTF-8 is replaced with U+FFFD.
+func quote(str string) string {
+	const hex = "0123456789abcdef"
+
+	out := make([]byte, 0, len(str)+2)
+	out = append(out, '"')
+
+	for i := 0; i < len(str); {
+		if b := str[i]; b < utf8.RuneSelf {
+			switch {
+			case b == '"' || b == '\\':
+				out = append(out, '\\', b)
+			case b == '\n':
+				out = append(out, '\\', 'n')
+			case b == '\r':
+				out = append(out, '\\', 'r')
+			case b == '\t':
+				out = append(out, '\\', 't')
+			case b < 0x20:
+				out = append(out, '\\', 'u', '0', '0', hex[b>>4], hex[b&0xF])
+			default:
+				out = append(out, b)
+			}
+
+			i++
+
+			continue
+		}
+
+		r, size := utf8.DecodeRuneInString(str[i:])
+
+		switch {
+		case r == utf8.RuneError && size == 1:
+			out = append(out, `\ufffd`...)
+		case r == '\u2028' || r == '\u2029':
+			// Valid JSON, but not valid JavaScript, so encoding/json escapes these too.
+			out = append(out, '\\', 'u', '2', '0', '2', hex[r&0xF])
+		default:
+			out = append(out, str[i:i+size]...)
+		}
+
+		i += size
+	}
+
+	return string(append(out, '"'))
 }
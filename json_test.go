@@ -0,0 +1,147 @@
+package instrument
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/netip"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
+)
+
+// jsonMarshaler and textMarshaler check that the marshaling interfaces are used.
+type (
+	jsonMarshaler struct{}
+	textMarshaler struct{}
+)
+
+func (jsonMarshaler) MarshalJSON() ([]byte, error) { return []byte(`{"custom": [1, "two"]}`), nil }
+func (textMarshaler) MarshalText() ([]byte, error) { return []byte("as text"), nil }
+
+// user is a struct without any marshaling methods of its own.
+type user struct {
+	Name    string   `json:"name"`
+	Tags    []string `json:"tags"`
+	private int
+}
+
+// jsonCases are values along with what encoding/json should decode them to.
+var jsonCases = []struct {
+	name  string
+	input any
+	want  any
+}{
+	{"quote", `say "hi"`, `say "hi"`},
+	{"newline", "one\ntwo\r\n", "one\ntwo\r\n"},
+	{"control characters", "\x00\x01\x1f\x7f\t", "\x00\x01\x1f\x7f\t"},
+	{"invalid UTF-8", "bad \xff\xfe byte", "bad \ufffd\ufffd byte"},
+	{"line separators", "a\u2028b\u2029c", "a\u2028b\u2029c"},
+	{"backslash", `C:\path`, `C:\path`},
+	{
+		"struct",
+		user{Name: "ada", Tags: []string{"admin"}, private: 1},
+		map[string]any{"name": "ada", "tags": []any{"admin"}},
+	},
+	{"struct pointer", &user{Name: "ada"}, map[string]any{"name": "ada", "tags": nil}},
+	{"typed slice", []int{1, 2, 3}, []any{1.0, 2.0, 3.0}},
+	{"nil slice", []string(nil), nil},
+	{"bytes", []byte("hello"), "aGVsbG8="},
+	{"int keys", map[int]string{2: "b", 1: "a"}, map[string]any{"1": "a", "2": "b"}},
+	{"text keys", map[netip.Addr]bool{netip.MustParseAddr("::1"): true}, map[string]any{"::1": true}},
+	{"nil pointer", (*user)(nil), nil},
+	{"nil map", map[string]int(nil), nil},
+	{
+		"nested",
+		Tags{"inner": map[string]any{"b": []any{true, nil}}},
+		map[string]any{"inner": map[string]any{"b": []any{true, nil}}},
+	},
+	{"json.Marshaler", jsonMarshaler{}, map[string]any{"custom": []any{1.0, "two"}}},
+	{"encoding.TextMarshaler", textMarshaler{}, "as text"},
+	{"error", errors.New("no \"luck\""), `no "luck"`},
+	{"time", time.Date(2024, 6, 5, 23, 39, 0, 0, time.UTC), "2024-06-05T23:39:00Z"},
+	{"NaN", math.NaN(), "NaN"},
+	{"+Inf", math.Inf(1), "+Inf"},
+	{"-Inf", float32(math.Inf(-1)), "-Inf"},
+	{"large float", 1e21, 1e21},
+	{"small float", 1e-7, 1e-7},
+	{"named float", time.Duration(0).Seconds(), 0.0},
+	{"complex", complex(1, 2), "(1+2i)"},
+	{"channel", make(chan int), nil},
+}
+
+func TestMarshalProducesValidJSON(t *testing.T) {
+	for _, tc := range jsonCases {
+		t.Run(tc.name, func(t *testing.T) {
+			out := marshalPlain(context.Background(), Tags{"value": tc.input, tc.name: "as a key"})
+
+			if !json.Valid(out) {
+				t.Fatalf("got invalid JSON: %s", out)
+			}
+
+			decoded := map[string]any{}
+			if err := json.Unmarshal(out, &decoded); err != nil {
+				t.Fatalf("could not decode %s: %v", out, err)
+			}
+
+			if !reflect.DeepEqual(decoded["value"], tc.want) {
+				t.Errorf("got %#v, want %#v from %s", decoded["value"], tc.want, out)
+			}
+		})
+	}
+}
+
+func TestQuoteEscapesKeys(t *testing.T) {
+	for _, tc := range jsonCases {
+		str, ok := tc.input.(string)
+		if !ok {
+			continue
+		}
+
+		out := marshalPlain(context.Background(), Tags{str: true})
+
+		decoded := map[string]any{}
+		if err := json.Unmarshal(out, &decoded); err != nil {
+			t.Errorf("could not decode %s: %v", out, err)
+
+			continue
+		}
+
+		if _, ok := decoded[tc.want.(string)]; !ok {
+			t.Errorf("key %q didn't survive: %s", str, out)
+		}
+	}
+}
+
+// ansi matches the escape sequences lipgloss uses for colors.
+var ansi = regexp.MustCompile("\x1b\\[[0-9;]*m")
+
+func TestColoredJSONMatchesPlain(t *testing.T) {
+	renderer := lipgloss.NewRenderer(nil)
+	renderer.SetColorProfile(termenv.TrueColor)
+
+	event := Tags{"meta.level": ERROR}
+	for _, tc := range jsonCases {
+		event[tc.name] = tc.input
+	}
+
+	plain := marshalPlain(context.Background(), event)
+
+	colored := bytes.Buffer{}
+	marshalEvent(context.Background(), event, &colored, colorPalette(levelStyle(event)).on(renderer))
+
+	if !strings.Contains(colored.String(), "\x1b[") {
+		t.Fatal("colored output has no colors")
+	}
+
+	if stripped := ansi.ReplaceAllString(colored.String(), ""); stripped != string(plain) {
+		t.Errorf("colored output differs from plain output without its colors:\n%s\n%s", stripped, plain)
+	}
+}
@@ -1,6 +1,13 @@
 package instrument
 
-import "github.com/charmbracelet/lipgloss"
+import (
+	"fmt"
+	"maps"
+	"strings"
+	"sync/atomic"
+
+	"github.com/charmbracelet/lipgloss"
+)
 
 // Level represents a standard logging level.
 type Level int
@@ -20,14 +27,72 @@ func (l Level) String() string {
 	return levelToName[l]
 }
 
+// MarshalText encodes the level as its short name, so that it can be used in config files.
+func (l Level) MarshalText() ([]byte, error) {
+	name, ok := levelToName[l]
+	if !ok {
+		return nil, fmt.Errorf("unknown level %d", l)
+	}
+
+	return []byte(name), nil
+}
+
+// UnmarshalText decodes any name accepted by ParseLevel, as well as METRIC's, so that every level round-trips.
+func (l *Level) UnmarshalText(text []byte) error {
+	level, err := parseLevel(string(text))
+	if err != nil {
+		return err
+	}
+
+	*l = level
+
+	return nil
+}
+
+// ParseLevel reads a level from its short name, such as DBG, or its full name, such as debug, in any case. It's meant
+// for minimum levels, so METRIC isn't accepted: as the highest level, it would hide every log.
+func ParseLevel(name string) (Level, error) {
+	level, err := parseLevel(name)
+	if err == nil && level == METRIC {
+		return INFO, fmt.Errorf("%q isn't a log level", name)
+	}
+
+	return level, err
+}
+
+// parseLevel reads any level's name.
+func parseLevel(name string) (Level, error) {
+	normalized := strings.ToUpper(strings.TrimSpace(name))
+
+	for level, short := range levelToName {
+		if normalized == short || normalized == levelFullNames[level] {
+			return level, nil
+		}
+	}
+
+	if normalized == "WARNING" {
+		return WARN, nil
+	}
+
+	return INFO, fmt.Errorf("unknown level %q", name)
+}
+
 // Style returns the configured lipgloss style for the level.
 func (l Level) Style() *lipgloss.Style {
-	return levelToColor[l]
+	return (*levelToColor.Load())[l]
 }
 
-// SetStyle changes the configured lipgloss style for the level.
+// SetStyle changes the configured lipgloss style for the level. It's safe to call while events are being emitted.
 func (l Level) SetStyle(s *lipgloss.Style) {
-	levelToColor[l] = s
+	for {
+		current := levelToColor.Load()
+		next := maps.Clone(*current)
+		next[l] = s
+
+		if levelToColor.CompareAndSwap(current, &next) {
+			return
+		}
+	}
 }
 
 // newStyle returns a lipgloss style for the given hexadecimal color.
@@ -48,7 +113,17 @@ var (
 		METRIC: "MET",
 	}
 
-	levelToColor = map[Level]*lipgloss.Style{
+	levelFullNames = map[Level]string{
+		TRACE:  "TRACE",
+		DEBUG:  "DEBUG",
+		INFO:   "INFO",
+		WARN:   "WARN",
+		ERROR:  "ERROR",
+		FATAL:  "FATAL",
+		METRIC: "METRIC",
+	}
+
+	defaultLevelColors = map[Level]*lipgloss.Style{
 		TRACE:  newStyle("#ff87e9"),
 		DEBUG:  newStyle("#ad7fa8"),
 		INFO:   newStyle("#34e2e2"),
@@ -58,3 +133,11 @@ var (
 		METRIC: newStyle("#daf0ee"),
 	}
 )
+
+// levelToColor holds the style for each level. SetStyle replaces it with a changed copy, so that it can be read while
+// it changes.
+var levelToColor atomic.Pointer[map[Level]*lipgloss.Style]
+
+func init() {
+	levelToColor.Store(&defaultLevelColors)
+}
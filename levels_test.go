@@ -0,0 +1,71 @@
+package instrument
+
+import "testing"
+
+func TestToggleRestoresLevel(t *testing.T) {
+	in := newTestInstrument(t)
+	in.SetLevel(WARN)
+
+	// This is what two SIGHUPs do.
+	in.SetDebug(!in.enabled(DEBUG))
+	if got := in.settings.Load().level; got != DEBUG {
+		t.Fatalf("level is %s after the first toggle, want DBG", got)
+	}
+
+	in.SetDebug(!in.enabled(DEBUG))
+	if got := in.settings.Load().level; got != WARN {
+		t.Errorf("level is %s after the second toggle, want WRN", got)
+	}
+
+	// Trace and debug toggles share the level from before either of them.
+	in.SetDebug(true)
+	in.SetTrace(true)
+	in.SetTrace(false)
+
+	if got := in.settings.Load().level; got != WARN {
+		t.Errorf("level is %s after toggling trace off, want WRN", got)
+	}
+
+	// Turning debug off can't go back to a level that shows it.
+	in.SetLevel(TRACE)
+	in.SetDebug(true)
+	in.SetDebug(false)
+
+	if got := in.settings.Load().level; got != INFO {
+		t.Errorf("level is %s after toggling debug off, want INF", got)
+	}
+
+	// An explicit level replaces the remembered one.
+	in.SetLevel(ERROR)
+	in.SetDebug(true)
+	in.SetLevel(DEBUG)
+	in.SetDebug(false)
+
+	if got := in.settings.Load().level; got != INFO {
+		t.Errorf("level is %s after SetLevel and a toggle, want INF", got)
+	}
+}
+
+func TestParseLevelRejectsMetric(t *testing.T) {
+	for _, name := range []string{"MET", "metric"} {
+		if _, err := ParseLevel(name); err == nil {
+			t.Errorf("ParseLevel(%q) should fail", name)
+		}
+	}
+
+	if level, err := ParseLevel("warning"); err != nil || level != WARN {
+		t.Errorf("ParseLevel(%q) = %s, %v; want WRN", "warning", level, err)
+	}
+
+	// Events at METRIC still have to round-trip.
+	var level Level
+	if err := level.UnmarshalText([]byte(METRIC.String())); err != nil || level != METRIC {
+		t.Errorf("could not unmarshal %s: %v", METRIC, err)
+	}
+
+	t.Setenv(envLevel, "metric")
+
+	if _, err := ConfigFromEnv(); err == nil {
+		t.Errorf("%s=metric should fail", envLevel)
+	}
+}
@@ -3,31 +3,66 @@ package instrument
 import (
 	"context"
 	"fmt"
+	"log/slog"
+	"maps"
 	"os"
-
-	"github.com/google/uuid"
+	"time"
 )
 
-const logCallerSkip = 3
+const logCallerSkip = 4
 
-var (
-	logsTotal    Counter = "instrument.logs.total"
-	logsErrors   Counter = "instrument.logs.errors"
-	logsWarnings Counter = "instrument.logs.warnings"
-)
+// fatalShutdownTimeout bounds how long Fatalf and friends wait for sinks before quitting.
+const fatalShutdownTimeout = 5 * time.Second
+
+// badKey is used for values without a key in the key-value API, the same way slog reports them.
+const badKey = "!BADKEY"
+
+// Enabled checks if logs at the given level would be emitted, so that expensive arguments can be skipped.
+func Enabled(ctx context.Context, level Level) bool {
+	return FromContext(ctx).enabled(level)
+}
+
+// Enabled checks if the instance would emit logs at the given level.
+func (in *Instrument) Enabled(_ context.Context, level Level) bool {
+	return in.enabled(level)
+}
+
+// logf emits a printf-style log message. Disabled levels return before doing any work.
+func (in *Instrument) logf(ctx context.Context, thisLevel Level, msg string, args ...interface{}) {
+	if !in.enabled(thisLevel) {
+		return
+	}
+
+	in.logEvent(ctx, thisLevel, fmt.Sprintf(msg, args...), nil)
+}
+
+// logkv emits a log message with key-value pairs for just this event.
+func (in *Instrument) logkv(ctx context.Context, thisLevel Level, msg string, kv []any) {
+	if !in.enabled(thisLevel) {
+		return
+	}
 
-// logf emits an event for a given message, with log-specific metadata.
-func logf(ctx context.Context, thisLevel Level, msg string, args ...interface{}) {
-	msg = fmt.Sprintf(msg, args...)
+	in.logEvent(ctx, thisLevel, msg, kvToTags(kv))
+}
+
+// logTags emits a log message with tags for just this event.
+func (in *Instrument) logTags(ctx context.Context, thisLevel Level, msg string, extra Tags) {
+	if !in.enabled(thisLevel) {
+		return
+	}
+
+	in.logEvent(ctx, thisLevel, msg, extra)
+}
+
+// logEvent emits an event for a given message, with log-specific metadata.
+func (in *Instrument) logEvent(ctx context.Context, thisLevel Level, msg string, extra Tags) {
 	caller, filename, line := getCaller(logCallerSkip)
 
-	logsTotal.Add()
+	in.countLog(thisLevel)
 	theseTags := tagsFromContext(ctx)
-	traceID := traceIDFromContext(ctx)
+	addTraceParent(ctx, theseTags)
 
-	if traceID != uuid.Nil {
-		theseTags["trace.parent"] = traceID
-	}
+	maps.Copy(theseTags, extra)
 
 	theseTags["meta.level"] = thisLevel
 	theseTags["meta.caller"] = caller
@@ -35,41 +70,258 @@ func logf(ctx context.Context, thisLevel Level, msg string, args ...interface{})
 	theseTags["meta.line"] = line
 	theseTags["log.message"] = msg
 
-	emit(ctx, theseTags)
+	in.emit(ctx, theseTags)
+}
+
+// countLog updates the instance's log counters.
+func (in *Instrument) countLog(level Level) {
+	in.logsTotal.Add()
+
+	switch level {
+	case WARN:
+		in.logsWarnings.Add()
+	case ERROR:
+		in.logsErrors.Add()
+	}
+}
+
+// exit shuts the instance down and quits the app.
+func (in *Instrument) exit() {
+	// We have to make sure to flush first, otherwise os.Exit() will destroy all telemtry we've collected.
+	ctx, cancel := context.WithTimeout(WithInstrument(context.Background(), in), fatalShutdownTimeout)
+	defer cancel()
+
+	if err := in.Shutdown(ctx); err != nil {
+		in.reportError(ctx, err)
+	}
+
+	os.Exit(1)
+}
+
+// kvToTags turns alternating keys and values into tags. Like slog, slog.Attr values are accepted in place of a pair,
+// and a value without a string key is kept under "!BADKEY". If there's more than one, they're all kept there, as a
+// list in the order they were given.
+func kvToTags(kv []any) Tags {
+	tags := make(Tags, len(kv)/2)
+	bad := []any{}
+
+	for len(kv) > 0 {
+		switch key := kv[0].(type) {
+		case string:
+			if len(kv) == 1 {
+				bad = append(bad, key)
+				kv = kv[1:]
+
+				continue
+			}
+
+			tags[key] = kv[1]
+			kv = kv[2:]
+		case slog.Attr:
+			addSlogAttr(tags, "", key)
+			kv = kv[1:]
+		default:
+			bad = append(bad, key)
+			kv = kv[1:]
+		}
+	}
+
+	switch len(bad) {
+	case 0:
+	case 1:
+		tags[badKey] = bad[0]
+	default:
+		tags[badKey] = bad
+	}
+
+	return tags
 }
 
 // Infof prints an informational string to the console.
 func Infof(ctx context.Context, msg string, args ...interface{}) {
-	logf(ctx, INFO, msg, args...)
+	FromContext(ctx).logf(ctx, INFO, msg, args...)
+}
+
+// Infof prints an informational string for the instance.
+func (in *Instrument) Infof(ctx context.Context, msg string, args ...interface{}) {
+	in.logf(ctx, INFO, msg, args...)
 }
 
 // Debugf prints debug information when in debug mode.
 func Debugf(ctx context.Context, msg string, args ...interface{}) {
-	logf(ctx, DEBUG, msg, args...)
+	FromContext(ctx).logf(ctx, DEBUG, msg, args...)
+}
+
+// Debugf prints debug information when the instance is in debug mode.
+func (in *Instrument) Debugf(ctx context.Context, msg string, args ...interface{}) {
+	in.logf(ctx, DEBUG, msg, args...)
 }
 
 // Tracef prints tracing information when in trace mode.
 func Tracef(ctx context.Context, msg string, args ...interface{}) {
-	logf(ctx, TRACE, msg, args...)
+	FromContext(ctx).logf(ctx, TRACE, msg, args...)
+}
+
+// Tracef prints tracing information when the instance is in trace mode.
+func (in *Instrument) Tracef(ctx context.Context, msg string, args ...interface{}) {
+	in.logf(ctx, TRACE, msg, args...)
 }
 
 // Errorf prints an error log to the console.
 func Errorf(ctx context.Context, msg string, args ...interface{}) {
-	logsErrors.Add()
-	logf(ctx, ERROR, msg, args...)
+	FromContext(ctx).logf(ctx, ERROR, msg, args...)
+}
+
+// Errorf prints an error log for the instance.
+func (in *Instrument) Errorf(ctx context.Context, msg string, args ...interface{}) {
+	in.logf(ctx, ERROR, msg, args...)
 }
 
 // Warnf prints a warning message.
 func Warnf(ctx context.Context, msg string, args ...interface{}) {
-	logsWarnings.Add()
-	logf(ctx, WARN, msg, args...)
+	FromContext(ctx).logf(ctx, WARN, msg, args...)
+}
+
+// Warnf prints a warning message for the instance.
+func (in *Instrument) Warnf(ctx context.Context, msg string, args ...interface{}) {
+	in.logf(ctx, WARN, msg, args...)
 }
 
 // Fatalf prints an error and quits the app.
 func Fatalf(ctx context.Context, msg string, args ...interface{}) {
-	logf(ctx, FATAL, msg, args...)
+	in := FromContext(ctx)
+	in.logf(ctx, FATAL, msg, args...)
+	in.exit()
+}
 
-	// We have to make sure to flush first, otherwise os.Exit() will destroy all telemtry we've collected.
-	Flush()
-	os.Exit(1)
+// Fatalf prints an error for the instance, flushes it, and quits the app.
+func (in *Instrument) Fatalf(ctx context.Context, msg string, args ...interface{}) {
+	in.logf(ctx, FATAL, msg, args...)
+	in.exit()
+}
+
+// Info emits an informational message, with key-value pairs for just this event.
+func Info(ctx context.Context, msg string, kv ...any) {
+	FromContext(ctx).logkv(ctx, INFO, msg, kv)
+}
+
+// Info emits an informational message for the instance, with key-value pairs for just this event.
+func (in *Instrument) Info(ctx context.Context, msg string, kv ...any) {
+	in.logkv(ctx, INFO, msg, kv)
+}
+
+// Debug emits a debug message when in debug mode, with key-value pairs for just this event.
+func Debug(ctx context.Context, msg string, kv ...any) {
+	FromContext(ctx).logkv(ctx, DEBUG, msg, kv)
+}
+
+// Debug emits a debug message when in debug mode for the instance, with key-value pairs for just this event.
+func (in *Instrument) Debug(ctx context.Context, msg string, kv ...any) {
+	in.logkv(ctx, DEBUG, msg, kv)
+}
+
+// Trace emits a tracing message when in trace mode, with key-value pairs for just this event.
+func Trace(ctx context.Context, msg string, kv ...any) {
+	FromContext(ctx).logkv(ctx, TRACE, msg, kv)
+}
+
+// Trace emits a tracing message when in trace mode for the instance, with key-value pairs for just this event.
+func (in *Instrument) Trace(ctx context.Context, msg string, kv ...any) {
+	in.logkv(ctx, TRACE, msg, kv)
+}
+
+// Warn emits a warning message, with key-value pairs for just this event.
+func Warn(ctx context.Context, msg string, kv ...any) {
+	FromContext(ctx).logkv(ctx, WARN, msg, kv)
+}
+
+// Warn emits a warning message for the instance, with key-value pairs for just this event.
+func (in *Instrument) Warn(ctx context.Context, msg string, kv ...any) {
+	in.logkv(ctx, WARN, msg, kv)
+}
+
+// Error emits an error message, with key-value pairs for just this event.
+func Error(ctx context.Context, msg string, kv ...any) {
+	FromContext(ctx).logkv(ctx, ERROR, msg, kv)
+}
+
+// Error emits an error message for the instance, with key-value pairs for just this event.
+func (in *Instrument) Error(ctx context.Context, msg string, kv ...any) {
+	in.logkv(ctx, ERROR, msg, kv)
+}
+
+// Fatal emits an error with key-value pairs for just this event, and quits the app.
+func Fatal(ctx context.Context, msg string, kv ...any) {
+	in := FromContext(ctx)
+	in.logkv(ctx, FATAL, msg, kv)
+	in.exit()
+}
+
+// Fatal emits an error for the instance with key-value pairs for just this event, flushes it, and quits the app.
+func (in *Instrument) Fatal(ctx context.Context, msg string, kv ...any) {
+	in.logkv(ctx, FATAL, msg, kv)
+	in.exit()
+}
+
+// InfoTags emits an informational message, with tags for just this event.
+func InfoTags(ctx context.Context, msg string, tags Tags) {
+	FromContext(ctx).logTags(ctx, INFO, msg, tags)
+}
+
+// InfoTags emits an informational message for the instance, with tags for just this event.
+func (in *Instrument) InfoTags(ctx context.Context, msg string, tags Tags) {
+	in.logTags(ctx, INFO, msg, tags)
+}
+
+// DebugTags emits a debug message when in debug mode, with tags for just this event.
+func DebugTags(ctx context.Context, msg string, tags Tags) {
+	FromContext(ctx).logTags(ctx, DEBUG, msg, tags)
+}
+
+// DebugTags emits a debug message when in debug mode for the instance, with tags for just this event.
+func (in *Instrument) DebugTags(ctx context.Context, msg string, tags Tags) {
+	in.logTags(ctx, DEBUG, msg, tags)
+}
+
+// TraceTags emits a tracing message when in trace mode, with tags for just this event.
+func TraceTags(ctx context.Context, msg string, tags Tags) {
+	FromContext(ctx).logTags(ctx, TRACE, msg, tags)
+}
+
+// TraceTags emits a tracing message when in trace mode for the instance, with tags for just this event.
+func (in *Instrument) TraceTags(ctx context.Context, msg string, tags Tags) {
+	in.logTags(ctx, TRACE, msg, tags)
+}
+
+// WarnTags emits a warning message, with tags for just this event.
+func WarnTags(ctx context.Context, msg string, tags Tags) {
+	FromContext(ctx).logTags(ctx, WARN, msg, tags)
+}
+
+// WarnTags emits a warning message for the instance, with tags for just this event.
+func (in *Instrument) WarnTags(ctx context.Context, msg string, tags Tags) {
+	in.logTags(ctx, WARN, msg, tags)
+}
+
+// ErrorTags emits an error message, with tags for just this event.
+func ErrorTags(ctx context.Context, msg string, tags Tags) {
+	FromContext(ctx).logTags(ctx, ERROR, msg, tags)
+}
+
+// ErrorTags emits an error message for the instance, with tags for just this event.
+func (in *Instrument) ErrorTags(ctx context.Context, msg string, tags Tags) {
+	in.logTags(ctx, ERROR, msg, tags)
+}
+
+// FatalTags emits an error with tags for just this event, and quits the app.
+func FatalTags(ctx context.Context, msg string, tags Tags) {
+	in := FromContext(ctx)
+	in.logTags(ctx, FATAL, msg, tags)
+	in.exit()
+}
+
+// FatalTags emits an error for the instance with tags for just this event, flushes it, and quits the app.
+func (in *Instrument) FatalTags(ctx context.Context, msg string, tags Tags) {
+	in.logTags(ctx, FATAL, msg, tags)
+	in.exit()
 }
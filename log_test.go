@@ -0,0 +1,55 @@
+package instrument
+
+import (
+	"context"
+	"log/slog"
+	"reflect"
+	"testing"
+)
+
+func TestKVToTags(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		kv   []any
+		want Tags
+	}{
+		{"pairs", []any{"path", "/", "ms", 42}, Tags{"path": "/", "ms": 42}},
+		{"trailing key", []any{"path", "/", "orphan"}, Tags{"path": "/", badKey: "orphan"}},
+		{"non-string key", []any{42, "path", "/"}, Tags{badKey: 42, "path": "/"}},
+		{
+			"attrs",
+			[]any{slog.Int("ms", 42), "path", "/", slog.Group("user", slog.String("id", "ada"))},
+			Tags{"ms": int64(42), "path": "/", "user.id": "ada"},
+		},
+		{"several bad keys", []any{1, "path", "/", 2.5, "orphan"}, Tags{badKey: []any{1, 2.5, "orphan"}, "path": "/"}},
+		{"empty", nil, Tags{}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := kvToTags(tc.kv); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got %#v, want %#v", got, tc.want)
+			}
+		})
+	}
+}
+
+func BenchmarkDebugfDisabled(b *testing.B) {
+	ctx := WithInstrument(context.Background(), newTestInstrument(b))
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		Debugf(ctx, "request %s took %dms", "/checkout", 42)
+	}
+}
+
+func BenchmarkDebugDisabled(b *testing.B) {
+	ctx := WithInstrument(context.Background(), newTestInstrument(b))
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		Debug(ctx, "request finished", "path", "/checkout", "ms", 42)
+	}
+}
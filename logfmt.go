@@ -0,0 +1,99 @@
+package instrument
+
+import (
+	"bytes"
+	"context"
+	"encoding"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// LogfmtEncoder writes events as key=value pairs, with keys in the color of the event's level.
+//
+// Values that need it are quoted and escaped like JSON strings. Maps, slices and structs are written as JSON.
+type LogfmtEncoder struct{}
+
+// Encode writes the event as logfmt.
+func (LogfmtEncoder) Encode(ctx context.Context, buf *bytes.Buffer, givenTags Tags, renderer *lipgloss.Renderer) error {
+	keyStyle := onRenderer(levelStyle(givenTags), renderer)
+
+	for i, key := range OrderedKeys(ctx, givenTags) {
+		if i > 0 {
+			buf.WriteByte(' ')
+		}
+
+		writeLogfmtPair(buf, key, givenTags[key], keyStyle)
+	}
+
+	return nil
+}
+
+// writeLogfmtPair writes a single key=value pair.
+func writeLogfmtPair(buf *bytes.Buffer, key string, val any, keyStyle *lipgloss.Style) {
+	buf.WriteString(sprintf(keyStyle, "%s", key))
+	buf.WriteByte('=')
+	buf.WriteString(logfmtValue(val))
+}
+
+// logfmtValue formats a value, quoting it if it's empty or would be ambiguous otherwise.
+func logfmtValue(val any) string {
+	var str string
+
+	switch typed := val.(type) {
+	case string:
+		str = typed
+	case error:
+		str = typed.Error()
+	case time.Time:
+		str = typed.UTC().Format(time.RFC3339)
+	case time.Duration:
+		str = typed.String()
+	case bool:
+		str = strconv.FormatBool(typed)
+	case float32:
+		str = strconv.FormatFloat(float64(typed), 'g', -1, 32)
+	case float64:
+		str = strconv.FormatFloat(typed, 'g', -1, 64)
+	case encoding.TextMarshaler:
+		text, err := typed.MarshalText()
+		if err != nil {
+			str = err.Error()
+		} else {
+			str = string(text)
+		}
+	default:
+		// Numbers come out of the JSON writer as they are, and anything else as JSON, which is quoted below.
+		buf := bytes.Buffer{}
+		marshalValue(val, &buf, palette{})
+		str = buf.String()
+
+		if unquoted, err := strconv.Unquote(str); err == nil && strings.HasPrefix(str, `"`) {
+			str = unquoted
+		}
+	}
+
+	if needsLogfmtQuotes(str) {
+		return quote(str)
+	}
+
+	return str
+}
+
+// needsLogfmtQuotes checks if a value has to be quoted to be read back.
+func needsLogfmtQuotes(str string) bool {
+	if str == "" {
+		return true
+	}
+
+	for _, r := range str {
+		if r == '=' || r == '"' || r == '\\' || unicode.IsSpace(r) || !unicode.IsPrint(r) {
+			return true
+		}
+	}
+
+	return false
+}
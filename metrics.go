@@ -19,14 +19,25 @@ package instrument
 // A histogram tracks the distribution of a stream of values (e.g. the number of milliseconds it takes to handle
 // requests), adding gauges for the values at meaningful quantiles: 50th, 75th, 90th, 95th, 99th, 99.9th.
 import (
+	"cmp"
 	"context"
+	"slices"
 	"sync"
-	"time"
+	"sync/atomic"
 
 	"github.com/HdrHistogram/hdrhistogram-go"
 	"github.com/pkg/errors"
 )
 
+// MetricSink is implemented by sinks that want each metric update as it happens, rather than totals on every Flush.
+//
+// The labels are nil for metrics outside a vec, and are shared, so sinks mustn't modify them.
+type MetricSink interface {
+	Count(name string, labels Tags, delta uint64)
+	Gauge(name string, labels Tags, value int64)
+	Histogram(name string, labels Tags, value int64)
+}
+
 // A Counter is a monotonically increasing unsigned integer.
 //
 // Use a counter to derive rates (e.g., record total number of requests, derive requests per second).
@@ -39,15 +50,58 @@ func (c Counter) Add() {
 
 // AddN increments the counter by N.
 func (c Counter) AddN(delta uint64) {
-	current, ok := counters.Load(c)
-	if ok {
-		current += delta
-		counters.Store(c, current)
-	} else {
-		counters.Store(c, delta)
+	defaultInstrument.NewCounter(string(c)).AddN(delta)
+}
+
+// A CounterHandle is a registered counter. Updating it is a single atomic add, so hot paths should hold on to one
+// rather than looking a Counter up by name on every update.
+type CounterHandle struct {
+	in     *Instrument
+	name   string
+	labels Tags
+	value  atomic.Uint64
+}
+
+// NewCounter registers a counter, or returns the existing one with the same name.
+func NewCounter(name string) *CounterHandle {
+	return defaultInstrument.NewCounter(name)
+}
+
+// NewCounter registers a counter with the instance, or returns the existing one with the same name.
+func (in *Instrument) NewCounter(name string) *CounterHandle {
+	if existing, ok := in.counters.Load(Counter(name)); ok {
+		return existing
+	}
+
+	handle, _ := in.counters.LoadOrStore(Counter(name), &CounterHandle{in: in, name: name})
+
+	return handle
+}
+
+// Name returns the name of the counter.
+func (ch *CounterHandle) Name() string {
+	return ch.name
+}
+
+// Add increments the counter by one.
+func (ch *CounterHandle) Add() {
+	ch.AddN(1)
+}
+
+// AddN increments the counter by N.
+func (ch *CounterHandle) AddN(delta uint64) {
+	ch.value.Add(delta)
+
+	for _, sink := range ch.in.sinks.Load().metric {
+		sink.Count(ch.name, ch.labels, delta)
 	}
 }
 
+// Value returns the current total.
+func (ch *CounterHandle) Value() uint64 {
+	return ch.value.Load()
+}
+
 // A Gauge is an instantaneous measurement of a value.
 //
 // Use a gauge to track metrics which increase and decrease (e.g., amount of free memory).
@@ -55,56 +109,130 @@ type Gauge string
 
 // Set the gauge's value to the given value.
 func (g Gauge) Set(value int64) {
-	gauges.Store(g, func() int64 {
-		return value
-	})
+	defaultInstrument.NewGauge(string(g)).Set(value)
 }
 
 // setBatchFunc sets the gauge's value to the lazily-called return value of the given function, with an additional
 // initializer function for a related batch of gauges, all of which are keyed by an arbitrary value.
 //
 // At the moment this is unexported because it's only used by histograms, and I want to keep the interface simple.
-func (g Gauge) setBatchFunc(key any, init func(), f func() int64) {
-	gauges.Store(g, f)
+func (in *Instrument) setBatchFunc(g Gauge, key any, init func(), f func() int64) {
+	in.NewGauge(string(g)).fn.Store(&f)
+
+	if _, ok := in.inits.Load(key); !ok {
+		in.inits.Store(key, init)
+	}
+}
+
+// A GaugeHandle is a registered gauge. Like CounterHandle, it saves looking the gauge up by name on every update.
+type GaugeHandle struct {
+	in     *Instrument
+	name   string
+	labels Tags
+	value  atomic.Int64
+	fn     atomic.Pointer[func() int64]
+}
+
+// NewGauge registers a gauge, or returns the existing one with the same name.
+func NewGauge(name string) *GaugeHandle {
+	return defaultInstrument.NewGauge(name)
+}
+
+// NewGauge registers a gauge with the instance, or returns the existing one with the same name.
+func (in *Instrument) NewGauge(name string) *GaugeHandle {
+	if existing, ok := in.gauges.Load(Gauge(name)); ok {
+		return existing
+	}
+
+	handle, _ := in.gauges.LoadOrStore(Gauge(name), &GaugeHandle{in: in, name: name})
+
+	return handle
+}
 
-	if _, ok := inits.Load(key); !ok {
-		inits.Store(key, init)
+// Name returns the name of the gauge.
+func (gh *GaugeHandle) Name() string {
+	return gh.name
+}
+
+// Set the gauge's value to the given value.
+func (gh *GaugeHandle) Set(value int64) {
+	gh.value.Store(value)
+	gh.fn.Store(nil)
+
+	for _, sink := range gh.in.sinks.Load().metric {
+		sink.Gauge(gh.name, gh.labels, value)
 	}
 }
 
+// Value returns the current value.
+func (gh *GaugeHandle) Value() int64 {
+	if fn := gh.fn.Load(); fn != nil {
+		return (*fn)()
+	}
+
+	return gh.value.Load()
+}
+
 type hname string // unexported to prevent collisions
 
+// quantiles are the percentiles that each histogram reports.
+var quantiles = []struct {
+	suffix string
+	q      float64
+}{
+	{"p50", 50},
+	{"p75", 75},
+	{"p90", 90},
+	{"p95", 95},
+	{"p99", 99},
+	{"p999", 99.9},
+}
+
 // NewHistogram returns a windowed HDR histogram which drops data older than five minutes.
 // The returned histogram is safe to use from multiple goroutines.
 //
 // Use a histogram to track the distribution of a stream of values (e.g., the latency associated with HTTP requests).
 func NewHistogram(name string, minValue, maxValue int64, sigfigs int) *Histogram {
-	if _, ok := histograms.Load(name); ok {
+	return defaultInstrument.NewHistogram(name, minValue, maxValue, sigfigs)
+}
+
+// NewHistogram registers a histogram with the instance, the same way as the package-level NewHistogram.
+func (in *Instrument) NewHistogram(name string, minValue, maxValue int64, sigfigs int) *Histogram {
+	if _, ok := in.histograms.Load(name); ok {
 		panic(name + " already exists")
 	}
 
-	hist := &Histogram{
-		name: name,
-		hist: hdrhistogram.NewWindowed(5, minValue, maxValue, sigfigs),
-	}
-	histograms.Store(name, hist)
+	hist := in.newHistogram(name, minValue, maxValue, sigfigs)
+	in.histograms.Store(name, hist)
 
-	Gauge(name+".p50").setBatchFunc(hname(name), hist.merge, hist.valueAt(50))
-	Gauge(name+".p75").setBatchFunc(hname(name), hist.merge, hist.valueAt(75))
-	Gauge(name+".p90").setBatchFunc(hname(name), hist.merge, hist.valueAt(90))
-	Gauge(name+".p95").setBatchFunc(hname(name), hist.merge, hist.valueAt(95))
-	Gauge(name+".p99").setBatchFunc(hname(name), hist.merge, hist.valueAt(99))
-	Gauge(name+".p999").setBatchFunc(hname(name), hist.merge, hist.valueAt(99.9))
+	for _, quantile := range quantiles {
+		in.setBatchFunc(Gauge(name+"."+quantile.suffix), hname(name), hist.merge, hist.valueAt(quantile.q))
+	}
 
 	return hist
 }
 
+// newHistogram creates a histogram without registering it or its quantile gauges.
+func (in *Instrument) newHistogram(name string, minValue, maxValue int64, sigfigs int) *Histogram {
+	return &Histogram{
+		in:   in,
+		name: name,
+		hist: hdrhistogram.NewWindowed(5, minValue, maxValue, sigfigs),
+	}
+}
+
 // A Histogram measures the distribution of a stream of values.
 type Histogram struct {
-	name string
-	hist *hdrhistogram.WindowedHistogram
-	m    *hdrhistogram.Histogram
-	rw   sync.RWMutex
+	in     *Instrument
+	name   string
+	labels Tags
+	hist   *hdrhistogram.WindowedHistogram
+	m      *hdrhistogram.Histogram
+	rw     sync.RWMutex
+
+	// Every recorded value, unlike the window, for exporters that need cumulative totals.
+	count int64
+	sum   int64
 }
 
 // Name returns the name of the histogram.
@@ -115,12 +243,21 @@ func (h *Histogram) Name() string {
 // RecordValue records the given value, or returns an error if the value is out of range.
 func (h *Histogram) RecordValue(v int64) error {
 	h.rw.Lock()
-	defer h.rw.Unlock()
-
 	err := h.hist.Current.RecordValue(v)
+	if err == nil {
+		h.count++
+		h.sum += v
+	}
+	h.rw.Unlock()
+
 	if err != nil {
 		return errors.Wrap(err, h.name)
 	}
+
+	for _, sink := range h.in.sinks.Load().metric {
+		sink.Histogram(h.name, h.labels, v)
+	}
+
 	return nil
 }
 
@@ -151,6 +288,36 @@ func (h *Histogram) valueAt(q float64) func() int64 {
 	}
 }
 
+// histogramPoint is a point-in-time view of a histogram for exporters.
+type histogramPoint struct {
+	name   string
+	labels Tags
+	count  int64
+	sum    int64
+	values []int64 // One for each of the quantiles.
+}
+
+// snapshot merges the current window, without replacing the one used by Flush.
+func (h *Histogram) snapshot() histogramPoint {
+	h.rw.RLock()
+	defer h.rw.RUnlock()
+
+	merged := h.hist.Merge()
+	point := histogramPoint{
+		name:   h.name,
+		labels: h.labels,
+		count:  h.count,
+		sum:    h.sum,
+		values: make([]int64, len(quantiles)),
+	}
+
+	for i, quantile := range quantiles {
+		point.values[i] = merged.ValueAtQuantile(quantile.q)
+	}
+
+	return point
+}
+
 type SyncMap[K comparable, V any] struct {
 	m sync.Map
 }
@@ -174,64 +341,195 @@ func (sm *SyncMap[K, V]) Store(key K, value V) {
 	sm.m.Store(key, value)
 }
 
-var (
-	counters   = SyncMap[Counter, uint64]{}
-	gauges     = SyncMap[Gauge, func() int64]{}
-	inits      = SyncMap[any, func()]{}
-	histograms = SyncMap[string, *Histogram]{}
+func (sm *SyncMap[K, V]) LoadOrStore(key K, value V) (V, bool) {
+	actual, loaded := sm.m.LoadOrStore(key, value)
+	return actual.(V), loaded
+}
+
+type (
+	counterPoint struct {
+		name   string
+		labels Tags
+		value  uint64
+	}
 
-	metricsTotal Gauge = "instrument.metrics.registered"
+	gaugePoint struct {
+		name   string
+		labels Tags
+		value  int64
+	}
 )
 
-// Flush is called on a given interval to emit the metrics events to all configured sinks.
-// It can also be called manually to immediately flush all known events.
+// metricsSnapshot holds the current value of every registered metric, sorted by name and then labels, for exporters.
+type metricsSnapshot struct {
+	counters   []counterPoint
+	gauges     []gaugePoint
+	histograms []histogramPoint
+}
+
+// snapshotMetrics reads every registry. Histograms are reported whole, rather than as their quantile gauges.
+func (in *Instrument) snapshotMetrics() metricsSnapshot {
+	snap := metricsSnapshot{}
+	histogramGauges := map[Gauge]bool{}
+
+	in.histograms.Range(func(name string, hist *Histogram) bool {
+		snap.histograms = append(snap.histograms, hist.snapshot())
+
+		for _, quantile := range quantiles {
+			histogramGauges[Gauge(name+"."+quantile.suffix)] = true
+		}
+
+		return true
+	})
+
+	in.counters.Range(func(key Counter, value *CounterHandle) bool {
+		snap.counters = append(snap.counters, counterPoint{name: string(key), value: value.Value()})
+
+		return true
+	})
+
+	in.gauges.Range(func(key Gauge, value *GaugeHandle) bool {
+		if !histogramGauges[key] {
+			snap.gauges = append(snap.gauges, gaugePoint{name: string(key), value: value.Value()})
+		}
+
+		return true
+	})
+
+	in.counterVecs.Range(func(_ string, vec *CounterVec) bool {
+		vec.vec.each(func(child *CounterHandle) {
+			snap.counters = append(snap.counters, counterPoint{name: child.name, labels: child.labels, value: child.Value()})
+		})
+
+		return true
+	})
+
+	in.gaugeVecs.Range(func(_ string, vec *GaugeVec) bool {
+		vec.vec.each(func(child *GaugeHandle) {
+			snap.gauges = append(snap.gauges, gaugePoint{name: child.name, labels: child.labels, value: child.Value()})
+		})
+
+		return true
+	})
+
+	in.histogramVecs.Range(func(_ string, vec *HistogramVec) bool {
+		vec.vec.each(func(child *Histogram) {
+			snap.histograms = append(snap.histograms, child.snapshot())
+		})
+
+		return true
+	})
+
+	slices.SortFunc(snap.counters, func(a, b counterPoint) int {
+		return cmp.Or(cmp.Compare(a.name, b.name), cmp.Compare(labelKey(a.labels), labelKey(b.labels)))
+	})
+	slices.SortFunc(snap.gauges, func(a, b gaugePoint) int {
+		return cmp.Or(cmp.Compare(a.name, b.name), cmp.Compare(labelKey(a.labels), labelKey(b.labels)))
+	})
+	slices.SortFunc(snap.histograms, func(a, b histogramPoint) int {
+		return cmp.Or(cmp.Compare(a.name, b.name), cmp.Compare(labelKey(a.labels), labelKey(b.labels)))
+	})
+
+	return snap
+}
+
+// Flush is called on a given interval to emit the metrics events to all configured sinks, and then to flush any
+// sinks that buffer events. It can also be called manually to immediately flush all known events.
 func Flush() {
+	defaultInstrument.Flush()
+}
+
+// Flush emits the instance's metrics events to its sinks, and then flushes any of them that buffer events.
+func (in *Instrument) Flush() {
+	in.emitMetrics()
+	in.flushSinks()
+}
+
+// emitMetrics emits an event with the current value of each of the instance's metrics.
+func (in *Instrument) emitMetrics() {
+	ctx := WithInstrument(context.Background(), in)
 	total := 0
-	inits.Range(func(key any, value func()) bool {
+	in.inits.Range(func(key any, value func()) bool {
 		value()
 		return true
 	})
 
-	counters.Range(func(key Counter, value uint64) bool {
+	in.counters.Range(func(key Counter, value *CounterHandle) bool {
 		total += 1
-		emit(context.Background(), Tags{
+		in.emit(ctx, Tags{
 			"metric.name":  string(key),
-			"metric.value": value,
+			"metric.value": value.Value(),
 			"meta.level":   METRIC,
 		})
 
 		return true
 	})
 
-	gauges.Range(func(key Gauge, value func() int64) bool {
+	in.gauges.Range(func(key Gauge, value *GaugeHandle) bool {
 		total += 1
-		emit(context.Background(), Tags{
+		in.emit(ctx, Tags{
 			"metric.name":  string(key),
-			"metric.value": value(),
+			"metric.value": value.Value(),
 			"meta.level":   METRIC,
 		})
 
 		return true
 	})
 
-	metricsTotal.Set(int64(total))
-	return
+	in.counterVecs.Range(func(_ string, vec *CounterVec) bool {
+		vec.vec.each(func(child *CounterHandle) {
+			total += 1
+			in.emitLabeled(ctx, child.name, child.labels, child.Value())
+		})
+
+		return true
+	})
+
+	in.gaugeVecs.Range(func(_ string, vec *GaugeVec) bool {
+		vec.vec.each(func(child *GaugeHandle) {
+			total += 1
+			in.emitLabeled(ctx, child.name, child.labels, child.Value())
+		})
+
+		return true
+	})
+
+	// Histograms in a vec don't register quantile gauges, so their quantiles are read here instead.
+	in.histogramVecs.Range(func(_ string, vec *HistogramVec) bool {
+		vec.vec.each(func(child *Histogram) {
+			child.merge()
+
+			for _, quantile := range quantiles {
+				total += 1
+				in.emitLabeled(ctx, child.name+"."+quantile.suffix, child.labels, child.valueAt(quantile.q)())
+			}
+		})
+
+		return true
+	})
+
+	in.metricsTotal.Set(int64(total))
 }
 
-func init() {
-	go func() {
-		for _ = range time.NewTicker(1 * time.Minute).C {
-			histograms.Range(func(key string, value *Histogram) bool {
-				value.rotate()
-				return true
-			})
-		}
-	}()
+// emitLabeled emits the value of a metric in a vec.
+func (in *Instrument) emitLabeled(ctx context.Context, name string, labels Tags, value any) {
+	in.emit(ctx, Tags{
+		"metric.name":   name,
+		"metric.labels": labels,
+		"metric.value":  value,
+		"meta.level":    METRIC,
+	})
+}
 
-	// TODO: make this configurable at runtime
-	go func() {
-		for _ = range time.NewTicker(1 * time.Minute).C {
-			Flush()
-		}
-	}()
+// rotateHistograms moves every histogram's window along.
+func (in *Instrument) rotateHistograms() {
+	in.histograms.Range(func(key string, value *Histogram) bool {
+		value.rotate()
+		return true
+	})
+
+	in.histogramVecs.Range(func(_ string, vec *HistogramVec) bool {
+		vec.vec.each((*Histogram).rotate)
+		return true
+	})
 }
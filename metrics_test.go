@@ -0,0 +1,184 @@
+package instrument
+
+import (
+	"sync"
+	"testing"
+)
+
+const (
+	hammerGoroutines = 32
+	hammerUpdates    = 1000
+)
+
+// hammer runs the function from many goroutines at once, passing each its index.
+func hammer(fn func(goroutine int)) {
+	wg := sync.WaitGroup{}
+
+	for g := 0; g < hammerGoroutines; g++ {
+		wg.Add(1)
+
+		go func(g int) {
+			defer wg.Done()
+
+			fn(g)
+		}(g)
+	}
+
+	wg.Wait()
+}
+
+func TestCounterConcurrentAdds(t *testing.T) {
+	counter := Counter(t.Name())
+
+	// The counter is on the default instance, so it keeps its value if the test runs again.
+	before := NewCounter(t.Name()).Value()
+
+	hammer(func(int) {
+		for i := 0; i < hammerUpdates; i++ {
+			counter.Add()
+		}
+	})
+
+	if got := NewCounter(t.Name()).Value() - before; got != hammerGoroutines*hammerUpdates {
+		t.Errorf("got %d, want %d", got, hammerGoroutines*hammerUpdates)
+	}
+}
+
+func TestCounterHandleConcurrentAdds(t *testing.T) {
+	in := newTestInstrument(t)
+
+	// Every goroutine registers the counter itself, so registration races with updates.
+	hammer(func(int) {
+		handle := in.NewCounter("test.hammered")
+
+		for i := 0; i < hammerUpdates; i++ {
+			handle.AddN(2)
+		}
+	})
+
+	if got := in.NewCounter("test.hammered").Value(); got != 2*hammerGoroutines*hammerUpdates {
+		t.Errorf("got %d, want %d", got, 2*hammerGoroutines*hammerUpdates)
+	}
+}
+
+func TestGaugeConcurrentSets(t *testing.T) {
+	gauge := Gauge(t.Name())
+
+	hammer(func(g int) {
+		for i := 0; i < hammerUpdates; i++ {
+			gauge.Set(int64(g))
+			_ = NewGauge(t.Name()).Value()
+		}
+	})
+
+	// The last write wins, so the value has to be one that a goroutine wrote.
+	if got := NewGauge(t.Name()).Value(); got < 0 || got >= hammerGoroutines {
+		t.Errorf("got %d, want a value between 0 and %d", got, hammerGoroutines-1)
+	}
+}
+
+// legacyCounters and legacyGauges are the registries from before handles, to benchmark against. Their updates aren't
+// atomic, which is what handles fixed.
+var (
+	legacyCounters SyncMap[Counter, uint64]
+	legacyGauges   SyncMap[Gauge, func() int64]
+)
+
+// legacyCounterAdd is the old Counter.AddN, without the metric sinks.
+func legacyCounterAdd(c Counter, delta uint64) {
+	current, ok := legacyCounters.Load(c)
+	if ok {
+		current += delta
+		legacyCounters.Store(c, current)
+	} else {
+		legacyCounters.Store(c, delta)
+	}
+}
+
+// legacyGaugeSet is the old Gauge.Set, without the metric sinks.
+func legacyGaugeSet(g Gauge, value int64) {
+	legacyGauges.Store(g, func() int64 {
+		return value
+	})
+}
+
+func BenchmarkCounterAdd(b *testing.B) {
+	b.Run("sync.Map", func(b *testing.B) {
+		b.ReportAllocs()
+
+		for i := 0; i < b.N; i++ {
+			legacyCounterAdd("bench.counter", 1)
+		}
+	})
+
+	b.Run("Counter", func(b *testing.B) {
+		b.ReportAllocs()
+
+		for i := 0; i < b.N; i++ {
+			Counter("bench.counter").Add()
+		}
+	})
+
+	b.Run("CounterHandle", func(b *testing.B) {
+		handle := NewCounter("bench.counter")
+
+		b.ReportAllocs()
+		b.ResetTimer()
+
+		for i := 0; i < b.N; i++ {
+			handle.Add()
+		}
+	})
+}
+
+func BenchmarkCounterAddParallel(b *testing.B) {
+	b.Run("sync.Map", func(b *testing.B) {
+		b.ReportAllocs()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				legacyCounterAdd("bench.counter.parallel", 1)
+			}
+		})
+	})
+
+	b.Run("CounterHandle", func(b *testing.B) {
+		handle := NewCounter("bench.counter.parallel")
+
+		b.ReportAllocs()
+		b.ResetTimer()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				handle.Add()
+			}
+		})
+	})
+}
+
+func BenchmarkGaugeSet(b *testing.B) {
+	b.Run("sync.Map", func(b *testing.B) {
+		b.ReportAllocs()
+
+		for i := 0; i < b.N; i++ {
+			legacyGaugeSet("bench.gauge", int64(i))
+		}
+	})
+
+	b.Run("Gauge", func(b *testing.B) {
+		b.ReportAllocs()
+
+		for i := 0; i < b.N; i++ {
+			Gauge("bench.gauge").Set(int64(i))
+		}
+	})
+
+	b.Run("GaugeHandle", func(b *testing.B) {
+		handle := NewGauge("bench.gauge")
+
+		b.ReportAllocs()
+		b.ResetTimer()
+
+		for i := 0; i < b.N; i++ {
+			handle.Set(int64(i))
+		}
+	})
+}
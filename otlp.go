@@ -0,0 +1,395 @@
+package instrument
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// otlpScopeName identifies instrument as the source of exported telemetry.
+	otlpScopeName = "github.com/gaylatea/instrument"
+
+	// defaultOTLPTimeout bounds each export request.
+	defaultOTLPTimeout = 10 * time.Second
+
+	// OTLP span kinds and status codes.
+	otlpSpanKindInternal = 1
+	otlpStatusError      = 2
+)
+
+// otlpTraceRootKey carries the trace's root span from the event's context into the batch. It's only ever set on
+// the exporter's own copy of an event.
+const otlpTraceRootKey = "trace.root"
+
+// otlpSpanKeys are the tags that become span fields rather than attributes.
+var otlpSpanKeys = map[string]bool{
+	"meta.instance":     true,
+	"meta.timestamp":    true,
+	"meta.level":        true,
+	"trace.id":          true,
+	"trace.parent":      true,
+	"trace.depth":       true,
+	"trace.name":        true,
+	"trace.start":       true,
+	"trace.duration.ms": true,
+	"trace.error":       true,
+	otlpTraceRootKey:    true,
+}
+
+// otlpAttributeNames maps instrument's metadata onto OpenTelemetry's semantic conventions.
+var otlpAttributeNames = map[string]string{
+	"meta.caller": "code.function",
+	"meta.file":   "code.filepath",
+	"meta.line":   "code.lineno",
+}
+
+// OTLPConfig controls where an OTLP exporter sends telemetry.
+type OTLPConfig struct {
+	// URL is the collector's OTLP/HTTP endpoint, such as http://localhost:4318/v1/traces.
+	URL string
+
+	// Headers are added to every request, for example to authenticate with the collector.
+	Headers map[string]string
+
+	// ServiceName is the service.name resource attribute. Empty uses the name of the running binary.
+	ServiceName string
+
+	// Resource holds extra resource attributes, such as deployment.environment.
+	Resource Tags
+
+	// Client sends the requests. Nil uses a client with a ten second timeout.
+	Client *http.Client
+
+	// Batch controls how many spans are sent in each request. Metrics are sent once per Flush.
+	Batch BatchConfig
+
+	// Instrument is the instance whose metrics an OTLPMetricsSink exports, and that an OTLPTraceSink reports its errors
+	// to. Nil uses the default instance.
+	Instrument *Instrument
+}
+
+// otlpExporter posts OTLP/JSON payloads to a collector.
+type otlpExporter struct {
+	config OTLPConfig
+}
+
+// newOTLPExporter fills in defaults for the config.
+func newOTLPExporter(config OTLPConfig) *otlpExporter {
+	if config.ServiceName == "" {
+		config.ServiceName = filepath.Base(os.Args[0])
+	}
+
+	if config.Client == nil {
+		config.Client = &http.Client{Timeout: defaultOTLPTimeout}
+	}
+
+	if config.Instrument == nil {
+		config.Instrument = defaultInstrument
+	}
+
+	return &otlpExporter{config: config}
+}
+
+// post sends a payload to the collector.
+func (oe *otlpExporter) post(ctx context.Context, payload any) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("could not encode OTLP payload: %w", err)
+	}
+
+	// The batch may have been triggered by an event whose context ends with its request.
+	ctx = context.WithoutCancel(ctx)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, oe.config.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("could not create OTLP request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	for key, val := range oe.config.Headers {
+		req.Header.Set(key, val)
+	}
+
+	resp, err := oe.config.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("could not export to OTLP collector: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+
+		return fmt.Errorf("OTLP collector returned %s: %s", resp.Status, bytes.TrimSpace(detail))
+	}
+
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	return nil
+}
+
+// resource describes the service and instance that produced some telemetry.
+func (oe *otlpExporter) resource(instance any) otlpResource {
+	attrs := []otlpKeyValue{
+		{Key: "service.name", Value: otlpValue(oe.config.ServiceName)},
+	}
+
+	if instance != nil {
+		attrs = append(attrs, otlpKeyValue{Key: "service.instance.id", Value: otlpValue(instance)})
+	}
+
+	attrs = append(attrs, otlpAttributes(oe.config.Resource, nil)...)
+
+	return otlpResource{Attributes: attrs}
+}
+
+// The OTLP/JSON encoding of the protobuf messages used here. 64-bit integers are strings, and IDs are hex.
+type (
+	otlpAnyValue struct {
+		StringValue *string  `json:"stringValue,omitempty"`
+		BoolValue   *bool    `json:"boolValue,omitempty"`
+		IntValue    *string  `json:"intValue,omitempty"`
+		DoubleValue *float64 `json:"doubleValue,omitempty"`
+	}
+
+	otlpKeyValue struct {
+		Key   string       `json:"key"`
+		Value otlpAnyValue `json:"value"`
+	}
+
+	otlpResource struct {
+		Attributes []otlpKeyValue `json:"attributes"`
+	}
+
+	otlpScope struct {
+		Name string `json:"name"`
+	}
+
+	otlpStatus struct {
+		Code    int    `json:"code,omitempty"`
+		Message string `json:"message,omitempty"`
+	}
+
+	otlpSpan struct {
+		TraceID           string         `json:"traceId"`
+		SpanID            string         `json:"spanId"`
+		ParentSpanID      string         `json:"parentSpanId,omitempty"`
+		Name              string         `json:"name"`
+		Kind              int            `json:"kind"`
+		StartTimeUnixNano string         `json:"startTimeUnixNano"`
+		EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+		Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+		Status            otlpStatus     `json:"status"`
+	}
+
+	otlpScopeSpans struct {
+		Scope otlpScope  `json:"scope"`
+		Spans []otlpSpan `json:"spans"`
+	}
+
+	otlpResourceSpans struct {
+		Resource   otlpResource     `json:"resource"`
+		ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+	}
+
+	otlpTraceRequest struct {
+		ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+	}
+)
+
+// otlpValue converts a tag value into the closest OTLP attribute type.
+func otlpValue(val any) otlpAnyValue {
+	switch typed := val.(type) {
+	case string:
+		return otlpAnyValue{StringValue: &typed}
+	case bool:
+		return otlpAnyValue{BoolValue: &typed}
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		str := fmt.Sprint(typed)
+
+		return otlpAnyValue{IntValue: &str}
+	case float32:
+		f := float64(typed)
+
+		return otlpAnyValue{DoubleValue: &f}
+	case float64:
+		return otlpAnyValue{DoubleValue: &typed}
+	case time.Time:
+		str := typed.UTC().Format(time.RFC3339Nano)
+
+		return otlpAnyValue{StringValue: &str}
+	case error:
+		str := typed.Error()
+
+		return otlpAnyValue{StringValue: &str}
+	default:
+		str := fmt.Sprint(typed)
+
+		return otlpAnyValue{StringValue: &str}
+	}
+}
+
+// otlpAttributes converts tags into sorted attributes, leaving out the given keys.
+func otlpAttributes(tags Tags, skip map[string]bool) []otlpKeyValue {
+	keys := make([]string, 0, len(tags))
+
+	for key := range tags {
+		if !skip[key] {
+			keys = append(keys, key)
+		}
+	}
+
+	slices.Sort(keys)
+
+	attrs := make([]otlpKeyValue, 0, len(keys))
+
+	for _, key := range keys {
+		name, ok := otlpAttributeNames[key]
+		if !ok {
+			name = key
+		}
+
+		attrs = append(attrs, otlpKeyValue{Key: name, Value: otlpValue(tags[key])})
+	}
+
+	return attrs
+}
+
+// otlpTime formats a timestamp as nanoseconds since the epoch.
+func otlpTime(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
+
+// otlpSpanID uses the random half of a span's UUIDv7 as its 8-byte OTLP span ID.
+func otlpSpanID(id uuid.UUID) string {
+	return hex.EncodeToString(id[8:])
+}
+
+// OTLPTraceSink exports spans from WithSpan to an OpenTelemetry collector over OTLP/HTTP. Other events are ignored.
+//
+// Each trace's outermost span ID becomes the 16-byte trace ID, and the random half of each span ID becomes the
+// 8-byte OTLP span ID. Spans are batched, and sent on Flush.
+type OTLPTraceSink struct {
+	exporter *otlpExporter
+	batch    *BatchedSink
+}
+
+// NewOTLPTraceSink creates an exporter for the collector in the config.
+func NewOTLPTraceSink(config OTLPConfig) *OTLPTraceSink {
+	ots := &OTLPTraceSink{exporter: newOTLPExporter(config)}
+
+	// The batch only gets the Events method, so that closing it doesn't close the sink again.
+	ots.batch = ots.exporter.config.Instrument.NewBatchedSink("otlp", otlpSpanBatches{ots}, config.Batch)
+
+	return ots
+}
+
+// Event adds spans to the current batch.
+func (ots *OTLPTraceSink) Event(ctx context.Context, givenTags Tags) error {
+	if _, ok := givenTags["trace.id"].(uuid.UUID); !ok {
+		return nil
+	}
+
+	span := maps.Clone(givenTags)
+	span[otlpTraceRootKey] = traceRootFromContext(ctx)
+
+	return ots.batch.Event(ctx, span)
+}
+
+// Flush sends the current batch.
+func (ots *OTLPTraceSink) Flush() error {
+	return ots.batch.Flush()
+}
+
+// Close sends the current batch.
+func (ots *OTLPTraceSink) Close() error {
+	return ots.batch.Close()
+}
+
+// otlpSpanBatches hides everything but an OTLPTraceSink's Events method from its BatchedSink.
+type otlpSpanBatches struct {
+	ots *OTLPTraceSink
+}
+
+// Events sends a batch of spans.
+func (osb otlpSpanBatches) Events(ctx context.Context, events []Tags) error {
+	return osb.ots.Events(ctx, events)
+}
+
+// Events sends a batch of spans as an ExportTraceServiceRequest, grouped by instance.
+func (ots *OTLPTraceSink) Events(ctx context.Context, events []Tags) error {
+	request := otlpTraceRequest{}
+	byInstance := map[any]int{}
+
+	for _, event := range events {
+		instance := event["meta.instance"]
+
+		i, ok := byInstance[instance]
+		if !ok {
+			i = len(request.ResourceSpans)
+			byInstance[instance] = i
+			request.ResourceSpans = append(request.ResourceSpans, otlpResourceSpans{
+				Resource:   ots.exporter.resource(instance),
+				ScopeSpans: []otlpScopeSpans{{Scope: otlpScope{Name: otlpScopeName}}},
+			})
+		}
+
+		scope := &request.ResourceSpans[i].ScopeSpans[0]
+		scope.Spans = append(scope.Spans, otlpSpanFromEvent(event))
+	}
+
+	return ots.exporter.post(ctx, request)
+}
+
+// otlpSpanFromEvent converts a span event from WithSpan.
+func otlpSpanFromEvent(event Tags) otlpSpan {
+	id, _ := event["trace.id"].(uuid.UUID)
+
+	root, _ := event[otlpTraceRootKey].(uuid.UUID)
+	if root == uuid.Nil {
+		root = id
+	}
+
+	name, _ := event["trace.name"].(string)
+	start, _ := event["trace.start"].(time.Time)
+
+	// The event is emitted as the span ends, so its timestamp is more precise than the rounded duration.
+	end, ok := event["meta.timestamp"].(time.Time)
+	if !ok {
+		duration, _ := event["trace.duration.ms"].(int64)
+		end = start.Add(time.Duration(duration) * time.Millisecond)
+	}
+
+	span := otlpSpan{
+		TraceID:           hex.EncodeToString(root[:]),
+		SpanID:            otlpSpanID(id),
+		Name:              name,
+		Kind:              otlpSpanKindInternal,
+		StartTimeUnixNano: otlpTime(start),
+		EndTimeUnixNano:   otlpTime(end),
+		Attributes:        otlpAttributes(event, otlpSpanKeys),
+	}
+
+	if parent, ok := event["trace.parent"].(uuid.UUID); ok && parent != uuid.Nil {
+		span.ParentSpanID = otlpSpanID(parent)
+	}
+
+	if err, ok := event["trace.error"].(error); ok && err != nil {
+		span.Status = otlpStatus{Code: otlpStatusError, Message: err.Error()}
+	}
+
+	return span
+}
@@ -0,0 +1,192 @@
+package instrument
+
+import (
+	"context"
+	"strconv"
+	"time"
+)
+
+// OTLP aggregation temporality for sums that only ever grow from the start of the process.
+const otlpCumulative = 2
+
+// processStart is the start time of every cumulative metric.
+var processStart = time.Now()
+
+// The OTLP/JSON encoding of the metrics protobuf messages.
+type (
+	otlpNumberDataPoint struct {
+		Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+		StartTimeUnixNano string         `json:"startTimeUnixNano,omitempty"`
+		TimeUnixNano      string         `json:"timeUnixNano"`
+		AsInt             string         `json:"asInt"`
+	}
+
+	otlpQuantileValue struct {
+		Quantile float64 `json:"quantile"`
+		Value    float64 `json:"value"`
+	}
+
+	otlpSummaryDataPoint struct {
+		Attributes        []otlpKeyValue      `json:"attributes,omitempty"`
+		StartTimeUnixNano string              `json:"startTimeUnixNano"`
+		TimeUnixNano      string              `json:"timeUnixNano"`
+		Count             string              `json:"count"`
+		Sum               float64             `json:"sum"`
+		QuantileValues    []otlpQuantileValue `json:"quantileValues"`
+	}
+
+	otlpSum struct {
+		DataPoints             []otlpNumberDataPoint `json:"dataPoints"`
+		AggregationTemporality int                   `json:"aggregationTemporality"`
+		IsMonotonic            bool                  `json:"isMonotonic"`
+	}
+
+	otlpGauge struct {
+		DataPoints []otlpNumberDataPoint `json:"dataPoints"`
+	}
+
+	otlpSummary struct {
+		DataPoints []otlpSummaryDataPoint `json:"dataPoints"`
+	}
+
+	otlpMetric struct {
+		Name    string       `json:"name"`
+		Sum     *otlpSum     `json:"sum,omitempty"`
+		Gauge   *otlpGauge   `json:"gauge,omitempty"`
+		Summary *otlpSummary `json:"summary,omitempty"`
+	}
+
+	otlpScopeMetrics struct {
+		Scope   otlpScope    `json:"scope"`
+		Metrics []otlpMetric `json:"metrics"`
+	}
+
+	otlpResourceMetrics struct {
+		Resource     otlpResource       `json:"resource"`
+		ScopeMetrics []otlpScopeMetrics `json:"scopeMetrics"`
+	}
+
+	otlpMetricsRequest struct {
+		ResourceMetrics []otlpResourceMetrics `json:"resourceMetrics"`
+	}
+)
+
+// OTLPMetricsSink exports every counter, gauge, and histogram to an OpenTelemetry collector over OTLP/HTTP each time
+// metrics are flushed. It reads the registries directly, so it ignores events.
+//
+// Counters become monotonic cumulative sums, gauges become gauges, and histograms become summaries of their current
+// window.
+type OTLPMetricsSink struct {
+	exporter *otlpExporter
+}
+
+// NewOTLPMetricsSink creates an exporter for the collector in the config.
+func NewOTLPMetricsSink(config OTLPConfig) *OTLPMetricsSink {
+	return &OTLPMetricsSink{exporter: newOTLPExporter(config)}
+}
+
+// Event ignores events, since metrics are read from the registries on Flush.
+func (oms *OTLPMetricsSink) Event(context.Context, Tags) error {
+	return nil
+}
+
+// Flush sends the current value of every metric.
+func (oms *OTLPMetricsSink) Flush() error {
+	in := oms.exporter.config.Instrument
+	metrics := otlpMetrics(in.snapshotMetrics(), time.Now())
+	if len(metrics) == 0 {
+		return nil
+	}
+
+	return oms.exporter.post(context.Background(), otlpMetricsRequest{
+		ResourceMetrics: []otlpResourceMetrics{{
+			Resource: oms.exporter.resource(in.id),
+			ScopeMetrics: []otlpScopeMetrics{{
+				Scope:   otlpScope{Name: otlpScopeName},
+				Metrics: metrics,
+			}},
+		}},
+	})
+}
+
+// otlpMetrics converts a snapshot of the registries. Metrics in a vec become one metric, with a data point for each
+// label set.
+func otlpMetrics(snap metricsSnapshot, now time.Time) []otlpMetric {
+	start, at := otlpTime(processStart), otlpTime(now)
+	metrics := []otlpMetric{}
+
+	for i, counter := range snap.counters {
+		point := otlpNumberDataPoint{
+			Attributes:        otlpAttributes(counter.labels, nil),
+			StartTimeUnixNano: start,
+			TimeUnixNano:      at,
+			AsInt:             strconv.FormatUint(counter.value, 10),
+		}
+
+		if i > 0 && snap.counters[i-1].name == counter.name {
+			sum := metrics[len(metrics)-1].Sum
+			sum.DataPoints = append(sum.DataPoints, point)
+
+			continue
+		}
+
+		metrics = append(metrics, otlpMetric{
+			Name: counter.name,
+			Sum: &otlpSum{
+				DataPoints:             []otlpNumberDataPoint{point},
+				AggregationTemporality: otlpCumulative,
+				IsMonotonic:            true,
+			},
+		})
+	}
+
+	for i, gauge := range snap.gauges {
+		point := otlpNumberDataPoint{
+			Attributes:   otlpAttributes(gauge.labels, nil),
+			TimeUnixNano: at,
+			AsInt:        strconv.FormatInt(gauge.value, 10),
+		}
+
+		if i > 0 && snap.gauges[i-1].name == gauge.name {
+			g := metrics[len(metrics)-1].Gauge
+			g.DataPoints = append(g.DataPoints, point)
+
+			continue
+		}
+
+		metrics = append(metrics, otlpMetric{
+			Name:  gauge.name,
+			Gauge: &otlpGauge{DataPoints: []otlpNumberDataPoint{point}},
+		})
+	}
+
+	for i, hist := range snap.histograms {
+		values := make([]otlpQuantileValue, len(quantiles))
+		for i, quantile := range quantiles {
+			values[i] = otlpQuantileValue{Quantile: quantile.q / 100, Value: float64(hist.values[i])}
+		}
+
+		point := otlpSummaryDataPoint{
+			Attributes:        otlpAttributes(hist.labels, nil),
+			StartTimeUnixNano: start,
+			TimeUnixNano:      at,
+			Count:             strconv.FormatInt(hist.count, 10),
+			Sum:               float64(hist.sum),
+			QuantileValues:    values,
+		}
+
+		if i > 0 && snap.histograms[i-1].name == hist.name {
+			summary := metrics[len(metrics)-1].Summary
+			summary.DataPoints = append(summary.DataPoints, point)
+
+			continue
+		}
+
+		metrics = append(metrics, otlpMetric{
+			Name:    hist.name,
+			Summary: &otlpSummary{DataPoints: []otlpSummaryDataPoint{point}},
+		})
+	}
+
+	return metrics
+}
@@ -0,0 +1,202 @@
+package instrument
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// collector is an OTLP/HTTP endpoint that keeps every request body it's sent.
+type collector struct {
+	mu     sync.Mutex
+	bodies [][]byte
+}
+
+// newCollector starts a collector that's stopped when the test finishes.
+func newCollector(t *testing.T) (*collector, string) {
+	t.Helper()
+
+	col := &collector{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Type") != "application/json" {
+			http.Error(w, "want JSON", http.StatusUnsupportedMediaType)
+
+			return
+		}
+
+		var body json.RawMessage
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+
+			return
+		}
+
+		col.mu.Lock()
+		col.bodies = append(col.bodies, body)
+		col.mu.Unlock()
+	}))
+
+	t.Cleanup(srv.Close)
+
+	return col, srv.URL
+}
+
+// decode unmarshals every request into the given type.
+func decode[T any](t *testing.T, col *collector) []T {
+	t.Helper()
+
+	col.mu.Lock()
+	defer col.mu.Unlock()
+
+	requests := make([]T, len(col.bodies))
+
+	for i, body := range col.bodies {
+		if err := json.Unmarshal(body, &requests[i]); err != nil {
+			t.Fatalf("could not decode request: %v", err)
+		}
+	}
+
+	return requests
+}
+
+func TestOTLPTraceSinkExportsSpans(t *testing.T) {
+	col, url := newCollector(t)
+	in := newTestInstrument(t)
+	sink := NewOTLPTraceSink(OTLPConfig{URL: url, ServiceName: "test"})
+
+	if err := in.UseSink("otlp", sink); err != nil {
+		t.Fatalf("could not add sink: %v", err)
+	}
+
+	failure := errors.New("inner failed")
+	ctx := WithInstrument(context.Background(), in)
+
+	_ = WithSpan(ctx, "outer", func(ctx context.Context, _ func(Tags)) error {
+		Infof(ctx, "not a span")
+
+		_ = WithSpan(ctx, "inner", func(context.Context, func(Tags)) error {
+			return failure
+		})
+
+		return nil
+	})
+
+	if err := sink.Flush(); err != nil {
+		t.Fatalf("could not flush: %v", err)
+	}
+
+	spans := map[string]otlpSpan{}
+
+	for _, request := range decode[otlpTraceRequest](t, col) {
+		for _, resourceSpans := range request.ResourceSpans {
+			for _, span := range resourceSpans.ScopeSpans[0].Spans {
+				spans[span.Name] = span
+			}
+		}
+	}
+
+	if len(spans) != 2 {
+		t.Fatalf("got spans %v, want outer and inner", spans)
+	}
+
+	outer, inner := spans["outer"], spans["inner"]
+
+	for _, span := range []otlpSpan{outer, inner} {
+		if len(span.TraceID) != 32 {
+			t.Errorf("span %s has trace ID %q, want 16 bytes of hex", span.Name, span.TraceID)
+		}
+
+		if len(span.SpanID) != 16 {
+			t.Errorf("span %s has span ID %q, want 8 bytes of hex", span.Name, span.SpanID)
+		}
+	}
+
+	if inner.TraceID != outer.TraceID {
+		t.Errorf("inner span is in trace %s, want %s", inner.TraceID, outer.TraceID)
+	}
+
+	if outer.ParentSpanID != "" {
+		t.Errorf("outer span has parent %s, want none", outer.ParentSpanID)
+	}
+
+	if inner.ParentSpanID != outer.SpanID {
+		t.Errorf("inner span has parent %s, want %s", inner.ParentSpanID, outer.SpanID)
+	}
+
+	if outer.Status.Code != 0 {
+		t.Errorf("outer span has status %+v, want unset", outer.Status)
+	}
+
+	if inner.Status.Code != otlpStatusError || inner.Status.Message != failure.Error() {
+		t.Errorf("inner span has status %+v, want an error", inner.Status)
+	}
+}
+
+func TestOTLPMetricsSinkExportsMetrics(t *testing.T) {
+	col, url := newCollector(t)
+	in := newTestInstrument(t)
+
+	requests := in.NewCounterVec("test.requests", "status")
+	requests.With("200").AddN(3)
+	requests.With("500").Add()
+	in.NewGauge("test.connections").Set(7)
+
+	latency := in.NewHistogram("test.latency", 1, 1000, 3)
+	for v := int64(1); v <= 100; v++ {
+		if err := latency.RecordValue(v); err != nil {
+			t.Fatalf("could not record %d: %v", v, err)
+		}
+	}
+
+	sink := NewOTLPMetricsSink(OTLPConfig{URL: url, ServiceName: "test", Instrument: in})
+	if err := sink.Flush(); err != nil {
+		t.Fatalf("could not flush: %v", err)
+	}
+
+	requestsSent := decode[otlpMetricsRequest](t, col)
+	if len(requestsSent) != 1 {
+		t.Fatalf("got %d requests, want 1", len(requestsSent))
+	}
+
+	metrics := map[string]otlpMetric{}
+	for _, metric := range requestsSent[0].ResourceMetrics[0].ScopeMetrics[0].Metrics {
+		metrics[metric.Name] = metric
+	}
+
+	counter := metrics["test.requests"]
+	if counter.Sum == nil || !counter.Sum.IsMonotonic || counter.Sum.AggregationTemporality != otlpCumulative {
+		t.Fatalf("test.requests is %+v, want a monotonic cumulative sum", counter)
+	}
+
+	counts := map[string]string{}
+
+	for _, point := range counter.Sum.DataPoints {
+		if len(point.Attributes) != 1 || point.Attributes[0].Key != "status" {
+			t.Fatalf("test.requests has attributes %+v, want status", point.Attributes)
+		}
+
+		counts[*point.Attributes[0].Value.StringValue] = point.AsInt
+	}
+
+	if counts["200"] != "3" || counts["500"] != "1" {
+		t.Errorf("test.requests has counts %v, want 200=3 and 500=1", counts)
+	}
+
+	gauge := metrics["test.connections"]
+	if gauge.Gauge == nil || len(gauge.Gauge.DataPoints) != 1 || gauge.Gauge.DataPoints[0].AsInt != "7" {
+		t.Errorf("test.connections is %+v, want a gauge of 7", gauge)
+	}
+
+	summary := metrics["test.latency"]
+	if summary.Summary == nil || len(summary.Summary.DataPoints) != 1 {
+		t.Fatalf("test.latency is %+v, want a summary", summary)
+	}
+
+	if point := summary.Summary.DataPoints[0]; point.Count != "100" || len(point.QuantileValues) == 0 {
+		t.Errorf("test.latency has data point %+v, want 100 values with quantiles", point)
+	}
+}
@@ -0,0 +1,160 @@
+package instrument
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	prometheusContentType  = "text/plain; version=0.0.4; charset=utf-8"
+	openMetricsContentType = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+)
+
+// PrometheusHandler serves every registered metric for Prometheus to scrape.
+//
+// Scrapers that accept OpenMetrics get that format, and everything else gets the Prometheus text format. Counters
+// and gauges are exported under their names with anything Prometheus doesn't allow replaced, so
+// instrument.logs.total becomes instrument_logs_total. Histograms are exported as summaries, with quantiles over the
+// current window and a cumulative count and sum.
+func PrometheusHandler() http.Handler {
+	return defaultInstrument.PrometheusHandler()
+}
+
+// PrometheusHandler serves the instance's metrics for Prometheus to scrape.
+func (in *Instrument) PrometheusHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		openMetrics := strings.Contains(r.Header.Get("Accept"), "application/openmetrics-text")
+		body := renderPrometheus(in.snapshotMetrics(), openMetrics)
+
+		if openMetrics {
+			w.Header().Set("Content-Type", openMetricsContentType)
+		} else {
+			w.Header().Set("Content-Type", prometheusContentType)
+		}
+
+		_, _ = w.Write(body)
+	})
+}
+
+// renderPrometheus writes a snapshot in the Prometheus text or OpenMetrics format. Metrics in a vec are written as
+// one family, with a sample for each label set.
+func renderPrometheus(snap metricsSnapshot, openMetrics bool) []byte {
+	buf := bytes.Buffer{}
+	family := ""
+
+	for _, counter := range snap.counters {
+		name := prometheusName(counter.name)
+		sample := name
+
+		// OpenMetrics names the counter family without the _total suffix, but always puts it on the sample.
+		if openMetrics {
+			name = strings.TrimSuffix(name, "_total")
+			sample = name + "_total"
+		}
+
+		if name != family {
+			family = name
+			fmt.Fprintf(&buf, "# TYPE %s counter\n", name)
+		}
+
+		fmt.Fprintf(&buf, "%s%s %d\n", sample, prometheusLabels(counter.labels, ""), counter.value)
+	}
+
+	for _, gauge := range snap.gauges {
+		name := prometheusName(gauge.name)
+
+		if name != family {
+			family = name
+			fmt.Fprintf(&buf, "# TYPE %s gauge\n", name)
+		}
+
+		fmt.Fprintf(&buf, "%s%s %d\n", name, prometheusLabels(gauge.labels, ""), gauge.value)
+	}
+
+	for _, hist := range snap.histograms {
+		name := prometheusName(hist.name)
+
+		if name != family {
+			family = name
+			fmt.Fprintf(&buf, "# TYPE %s summary\n", name)
+		}
+
+		for i, quantile := range quantiles {
+			q := strconv.FormatFloat(quantile.q/100, 'g', 6, 64)
+			fmt.Fprintf(&buf, "%s%s %d\n", name, prometheusLabels(hist.labels, `quantile="`+q+`"`), hist.values[i])
+		}
+
+		labels := prometheusLabels(hist.labels, "")
+		fmt.Fprintf(&buf, "%s_sum%s %d\n%s_count%s %d\n", name, labels, hist.sum, name, labels, hist.count)
+	}
+
+	if openMetrics {
+		buf.WriteString("# EOF\n")
+	}
+
+	return buf.Bytes()
+}
+
+// prometheusLabels formats labels, sorted by name, along with an extra label that's already formatted.
+func prometheusLabels(labels Tags, extra string) string {
+	keys := make([]string, 0, len(labels))
+	for key := range labels {
+		keys = append(keys, key)
+	}
+
+	slices.Sort(keys)
+
+	pairs := make([]string, 0, len(keys)+1)
+	escaper := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+	for _, key := range keys {
+		pairs = append(pairs, prometheusLabelName(key)+`="`+escaper.Replace(fmt.Sprint(labels[key]))+`"`)
+	}
+
+	if extra != "" {
+		pairs = append(pairs, extra)
+	}
+
+	if len(pairs) == 0 {
+		return ""
+	}
+
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// prometheusName replaces any characters that aren't allowed in a Prometheus metric name with underscores.
+func prometheusName(name string) string {
+	return prometheusSanitize(name, true)
+}
+
+// prometheusLabelName is like prometheusName, but for label names, which can't have colons.
+func prometheusLabelName(name string) string {
+	return prometheusSanitize(name, false)
+}
+
+// prometheusSanitize replaces any characters that aren't letters, underscores, or digits after the first character
+// with underscores. Colons are allowed only if the flag is set.
+func prometheusSanitize(name string, colons bool) string {
+	var builder strings.Builder
+
+	for i, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r == '_', r == ':' && colons:
+			builder.WriteRune(r)
+		case r >= '0' && r <= '9':
+			if i == 0 {
+				builder.WriteRune('_')
+			}
+
+			builder.WriteRune(r)
+		default:
+			builder.WriteRune('_')
+		}
+	}
+
+	return builder.String()
+}
@@ -0,0 +1,146 @@
+package instrument
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// scrape fetches an instance's metrics, asking for OpenMetrics if the flag is set.
+func scrape(t *testing.T, in *Instrument, openMetrics bool) (string, string) {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	if openMetrics {
+		req.Header.Set("Accept", "application/openmetrics-text; version=1.0.0,text/plain;q=0.5")
+	}
+
+	rec := httptest.NewRecorder()
+	in.PrometheusHandler().ServeHTTP(rec, req)
+
+	return rec.Body.String(), rec.Header().Get("Content-Type")
+}
+
+// checkFamilies checks that every family has one TYPE line, with all of its samples right after it.
+func checkFamilies(t *testing.T, body string) {
+	t.Helper()
+
+	seen := map[string]bool{}
+	family := ""
+
+	for _, line := range strings.Split(strings.TrimSpace(body), "\n") {
+		if fields := strings.Fields(line); len(fields) == 4 && fields[1] == "TYPE" {
+			family = fields[2]
+
+			if seen[family] {
+				t.Errorf("family %s has more than one TYPE line", family)
+			}
+
+			seen[family] = true
+
+			continue
+		}
+
+		if line == "# EOF" {
+			continue
+		}
+
+		if !strings.HasPrefix(line, family) {
+			t.Errorf("sample %q isn't with its family, after %s", line, family)
+		}
+	}
+}
+
+func newPrometheusInstrument(t *testing.T) *Instrument {
+	t.Helper()
+
+	in := newTestInstrument(t)
+
+	requests := in.NewCounterVec("http.requests.total", "status", "client:id")
+	requests.With("500", "web").Add()
+	requests.With("200", "web").AddN(3)
+	in.NewCounter("2xx-responses").AddN(2)
+	in.NewGaugeVec("queue.depth", "path").With("C:\\queue \"main\"\nnext").Set(-4)
+
+	latency := in.NewHistogram("db.latency", 1, 1000, 3)
+	for v := int64(1); v <= 100; v++ {
+		if err := latency.RecordValue(v); err != nil {
+			t.Fatalf("could not record %d: %v", v, err)
+		}
+	}
+
+	return in
+}
+
+func TestPrometheusText(t *testing.T) {
+	body, contentType := scrape(t, newPrometheusInstrument(t), false)
+
+	if contentType != prometheusContentType {
+		t.Errorf("got content type %q", contentType)
+	}
+
+	checkFamilies(t, body)
+
+	for _, want := range []string{
+		"# TYPE http_requests_total counter\n" +
+			`http_requests_total{client_id="web",status="200"} 3` + "\n" +
+			`http_requests_total{client_id="web",status="500"} 1` + "\n",
+		"# TYPE _2xx_responses counter\n_2xx_responses 2\n",
+		`queue_depth{path="C:\\queue \"main\"\nnext"} -4`,
+		"# TYPE db_latency summary\n",
+		`db_latency{quantile="0.5"} 50`,
+		`db_latency{quantile="0.99"} 99`,
+		"db_latency_sum 5050\ndb_latency_count 100\n",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("body doesn't have %q:\n%s", want, body)
+		}
+	}
+
+	if strings.Contains(body, "# EOF") {
+		t.Error("text format has an OpenMetrics EOF")
+	}
+}
+
+func TestPrometheusOpenMetrics(t *testing.T) {
+	body, contentType := scrape(t, newPrometheusInstrument(t), true)
+
+	if contentType != openMetricsContentType {
+		t.Errorf("got content type %q", contentType)
+	}
+
+	checkFamilies(t, body)
+
+	for _, want := range []string{
+		"# TYPE http_requests counter\n" + `http_requests_total{client_id="web",status="200"} 3`,
+		"# TYPE _2xx_responses counter\n_2xx_responses_total 2\n",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("body doesn't have %q:\n%s", want, body)
+		}
+	}
+
+	if !strings.HasSuffix(body, "\n# EOF\n") {
+		t.Errorf("body doesn't end with # EOF:\n%s", body)
+	}
+}
+
+func TestPrometheusNames(t *testing.T) {
+	for _, tc := range []struct {
+		name, metric, label string
+	}{
+		{"http.requests", "http_requests", "http_requests"},
+		{"ns:sub.name", "ns:sub_name", "ns_sub_name"},
+		{"9lives", "_9lives", "_9lives"},
+		{"café-latency", "caf__latency", "caf__latency"},
+	} {
+		if got := prometheusName(tc.name); got != tc.metric {
+			t.Errorf("prometheusName(%q) = %q, want %q", tc.name, got, tc.metric)
+		}
+
+		if got := prometheusLabelName(tc.name); got != tc.label {
+			t.Errorf("prometheusLabelName(%q) = %q, want %q", tc.name, got, tc.label)
+		}
+	}
+}
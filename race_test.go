@@ -0,0 +1,138 @@
+package instrument
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"testing"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// These tests emit while the instance is being reconfigured. They're most useful with -race.
+
+const raceIterations = 200
+
+// reconfigure runs each change in a loop alongside goroutines that emit every kind of event,
+// until all of them are done.
+func reconfigure(t *testing.T, in *Instrument, changes ...func(i int)) {
+	t.Helper()
+
+	ctx := WithInstrument(context.Background(), in)
+	counter := in.NewCounter("test.race")
+	wg := sync.WaitGroup{}
+
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for i := 0; i < raceIterations; i++ {
+				Debugf(ctx, "debug %d", i)
+				Info(With(ctx, "n", i), "key-value", "i", i)
+				_ = WithSpan(ctx, "race", func(ctx context.Context, _ func(Tags)) error {
+					Tracef(ctx, "trace %d", i)
+
+					return nil
+				})
+				counter.Add()
+			}
+		}()
+	}
+
+	for _, change := range changes {
+		wg.Add(1)
+
+		go func(change func(int)) {
+			defer wg.Done()
+
+			for i := 0; i < raceIterations; i++ {
+				change(i)
+			}
+		}(change)
+	}
+
+	wg.Wait()
+	in.Flush()
+}
+
+func TestRaceSinks(t *testing.T) {
+	in := newTestInstrument(t)
+	kept := &memorySink{}
+
+	if err := in.UseSink("kept", kept); err != nil {
+		t.Fatalf("could not add sink: %v", err)
+	}
+
+	reconfigure(t, in, func(i int) {
+		name := fmt.Sprintf("sink-%d", i%4)
+
+		if err := in.UseSink(name, &memorySink{}, SinkLevel(INFO)); err != nil {
+			// Another iteration may have added it first.
+			_ = in.RemoveSink(name)
+		}
+	}, func(i int) {
+		_ = in.RemoveSink(fmt.Sprintf("sink-%d", (i+2)%4))
+	})
+
+	if len(kept.Events()) == 0 {
+		t.Error("sink that stayed registered got no events")
+	}
+}
+
+func TestRaceSettings(t *testing.T) {
+	in := newTestInstrument(t)
+
+	if err := in.UseSink("memory", &memorySink{}); err != nil {
+		t.Fatalf("could not add sink: %v", err)
+	}
+
+	// Silence is toggled below, so the terminal would print.
+	if err := in.RemoveSink("terminal"); err != nil {
+		t.Fatalf("could not remove terminal: %v", err)
+	}
+
+	levels := []Level{TRACE, DEBUG, INFO, WARN}
+
+	reconfigure(t, in, func(i int) {
+		in.SetDebug(i%2 == 0)
+	}, func(i int) {
+		in.SetTrace(i%3 == 0)
+	}, func(i int) {
+		in.SetLevel(levels[i%len(levels)])
+	}, func(i int) {
+		in.Silence(i%2 == 0)
+	})
+}
+
+func TestRaceStyles(t *testing.T) {
+	in := newTestInstrument(t)
+
+	// Console output reads level styles for every event.
+	if err := in.UseSink("console", NewWriterSink(io.Discard, ConsoleEncoder{})); err != nil {
+		t.Fatalf("could not add sink: %v", err)
+	}
+
+	in.SetLevel(TRACE)
+
+	// Styles are shared by every instance, so they're put back afterwards.
+	styled := []Level{TRACE, DEBUG, INFO}
+	original := make([]*lipgloss.Style, len(styled))
+
+	for i, level := range styled {
+		original[i] = level.Style()
+	}
+
+	t.Cleanup(func() {
+		for i, level := range styled {
+			level.SetStyle(original[i])
+		}
+	})
+
+	reconfigure(t, in, func(i int) {
+		style := lipgloss.NewStyle().Foreground(lipgloss.Color(fmt.Sprint(i % 16)))
+		styled[i%len(styled)].SetStyle(&style)
+	})
+}
@@ -0,0 +1,106 @@
+package instrument
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"sync"
+)
+
+// memorySink keeps every event it's given, and counts how often it's flushed and closed.
+type memorySink struct {
+	mu      sync.Mutex
+	events  []Tags
+	flushes int
+	closes  int
+}
+
+func (ms *memorySink) Event(_ context.Context, givenTags Tags) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	ms.events = append(ms.events, maps.Clone(givenTags))
+
+	return nil
+}
+
+func (ms *memorySink) Flush() error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	ms.flushes++
+
+	return nil
+}
+
+func (ms *memorySink) Close() error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	ms.closes++
+
+	return nil
+}
+
+// Events returns a copy of the events so far.
+func (ms *memorySink) Events() []Tags {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	return append([]Tags(nil), ms.events...)
+}
+
+// Closes returns how many times the sink was closed.
+func (ms *memorySink) Closes() int {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	return ms.closes
+}
+
+// memoryBatchSink keeps every batch it's given, and can be made to fail.
+type memoryBatchSink struct {
+	mu      sync.Mutex
+	batches [][]Tags
+	fail    bool
+	closes  int
+}
+
+func (mb *memoryBatchSink) Events(_ context.Context, events []Tags) error {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+
+	if mb.fail {
+		mb.fail = false
+
+		return errBatchFailed
+	}
+
+	mb.batches = append(mb.batches, events)
+
+	return nil
+}
+
+func (mb *memoryBatchSink) Close() error {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+
+	mb.closes++
+
+	return nil
+}
+
+// delivered counts the events in every batch.
+func (mb *memoryBatchSink) delivered() int {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+
+	total := 0
+	for _, batch := range mb.batches {
+		total += len(batch)
+	}
+
+	return total
+}
+
+var errBatchFailed = errors.New("batch failed")
@@ -0,0 +1,67 @@
+package instrument
+
+// A SinkOption limits which events a sink receives.
+type SinkOption func(*sinkOptions)
+
+// sinkOptions are the limits set by SinkOptions. The zero value lets every event through.
+type sinkOptions struct {
+	level     Level
+	filter    func(Tags) bool
+	noMetrics bool
+}
+
+// SinkLevel only sends the sink logs at the given level or above. Events without a level, such as those from
+// PostEvent, are always sent, and so are METRIC events unless SinkMetrics excludes them.
+func SinkLevel(level Level) SinkOption {
+	return func(opts *sinkOptions) {
+		opts.level = level
+	}
+}
+
+// SinkFilter only sends the sink events that the function returns true for. The function mustn't modify the tags.
+func SinkFilter(filter func(Tags) bool) SinkOption {
+	return func(opts *sinkOptions) {
+		opts.filter = filter
+	}
+}
+
+// SinkMetrics includes or excludes METRIC events, and metric updates for sinks that implement MetricSink. They're
+// included by default.
+func SinkMetrics(include bool) SinkOption {
+	return func(opts *sinkOptions) {
+		opts.noMetrics = !include
+	}
+}
+
+// registeredSink is a sink along with the options it was registered with.
+type registeredSink struct {
+	sink Sink
+	sinkOptions
+}
+
+// newRegisteredSink applies the options to a sink.
+func newRegisteredSink(sink Sink, opts []SinkOption) registeredSink {
+	rs := registeredSink{sink: sink}
+
+	for _, opt := range opts {
+		opt(&rs.sinkOptions)
+	}
+
+	return rs
+}
+
+// accepts checks if the sink's options let the event through.
+func (rs registeredSink) accepts(givenTags Tags) bool {
+	if level, ok := givenTags["meta.level"].(Level); ok {
+		// METRIC sorts above every log level, so it's left out of the comparison and only SinkMetrics excludes it.
+		if level == METRIC {
+			if rs.noMetrics {
+				return false
+			}
+		} else if level < rs.level {
+			return false
+		}
+	}
+
+	return rs.filter == nil || rs.filter(givenTags)
+}
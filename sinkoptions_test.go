@@ -0,0 +1,112 @@
+package instrument
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+)
+
+// memoryMetricSink is a memorySink that also counts metric updates.
+type memoryMetricSink struct {
+	memorySink
+	updates atomic.Int64
+}
+
+func (mm *memoryMetricSink) Count(string, Tags, uint64)    { mm.updates.Add(1) }
+func (mm *memoryMetricSink) Gauge(string, Tags, int64)     { mm.updates.Add(1) }
+func (mm *memoryMetricSink) Histogram(string, Tags, int64) { mm.updates.Add(1) }
+
+// levels counts the sink's events by level, with events that have none under -1.
+func levels(sink *memorySink) map[Level]int {
+	counts := map[Level]int{}
+
+	for _, event := range sink.Events() {
+		level, ok := event["meta.level"].(Level)
+		if !ok {
+			level = -1
+		}
+
+		counts[level]++
+	}
+
+	return counts
+}
+
+func TestSinkLevel(t *testing.T) {
+	in := newTestInstrument(t)
+	sink := &memorySink{}
+
+	if err := in.UseSink("warnings", sink, SinkLevel(WARN)); err != nil {
+		t.Fatalf("could not add sink: %v", err)
+	}
+
+	ctx := WithInstrument(context.Background(), in)
+	Infof(ctx, "not sent")
+	Warnf(ctx, "sent")
+	Errorf(ctx, "sent")
+	PostEvent(ctx, "sent", Tags{})
+	in.NewCounter("test.sent").Add()
+	in.Flush()
+
+	got := levels(sink)
+	if got[INFO] != 0 || got[WARN] != 1 || got[ERROR] != 1 || got[-1] != 1 {
+		t.Errorf("got events by level %v, want one each of WRN, ERR and no level", got)
+	}
+
+	// Metrics are above every log level, but SinkLevel doesn't affect them.
+	if got[METRIC] == 0 {
+		t.Error("SinkLevel excluded metrics")
+	}
+}
+
+func TestSinkFilter(t *testing.T) {
+	in := newTestInstrument(t)
+	sink := &memorySink{}
+
+	err := in.UseSink("payments", sink, SinkFilter(func(givenTags Tags) bool {
+		return givenTags["team"] == "payments"
+	}))
+	if err != nil {
+		t.Fatalf("could not add sink: %v", err)
+	}
+
+	ctx := WithInstrument(context.Background(), in)
+	Infof(With(ctx, "team", "payments"), "sent")
+	Infof(With(ctx, "team", "search"), "not sent")
+	Infof(ctx, "not sent")
+
+	events := sink.Events()
+	if len(events) != 1 || events[0]["log.message"] != "sent" {
+		t.Errorf("got events %v, want only the payments one", events)
+	}
+}
+
+func TestSinkMetrics(t *testing.T) {
+	in := newTestInstrument(t)
+	included, excluded := &memoryMetricSink{}, &memoryMetricSink{}
+
+	if err := in.UseSink("included", included); err != nil {
+		t.Fatalf("could not add sink: %v", err)
+	}
+
+	if err := in.UseSink("excluded", excluded, SinkMetrics(false)); err != nil {
+		t.Fatalf("could not add sink: %v", err)
+	}
+
+	in.NewCounter("test.updates").Add()
+	in.NewGauge("test.gauge").Set(1)
+	Warnf(WithInstrument(context.Background(), in), "sent to both")
+	in.Flush()
+
+	if included.updates.Load() == 0 || levels(&included.memorySink)[METRIC] == 0 {
+		t.Error("sink didn't get metrics by default")
+	}
+
+	if got := excluded.updates.Load(); got != 0 {
+		t.Errorf("sink got %d metric updates after SinkMetrics(false)", got)
+	}
+
+	if got := levels(&excluded.memorySink); got[METRIC] != 0 || got[WARN] != 1 {
+		t.Errorf("got events by level %v, want only the warning", got)
+	}
+}
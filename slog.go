@@ -0,0 +1,211 @@
+package instrument
+
+import (
+	"context"
+	"log/slog"
+	"maps"
+	"runtime"
+	"time"
+)
+
+// SlogHandler is a slog.Handler that emits records as instrument logs, so that libraries using log/slog go through
+// the same sinks.
+//
+// Record attributes become tags, with groups joined to their keys by dots. Tags and the current span from the
+// context are added the same way as for Infof and friends, and records go to the context's instance.
+type SlogHandler struct {
+	attrs  Tags
+	prefix string
+}
+
+// NewSlogHandler returns a handler for use with slog.New.
+func NewSlogHandler() *SlogHandler {
+	return &SlogHandler{attrs: Tags{}}
+}
+
+// Enabled checks if the context's instance is emitting events at the record's level.
+func (sh *SlogHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return FromContext(ctx).enabled(levelFromSlog(level))
+}
+
+// Handle emits a record. Records below the instance's level are dropped here too, for callers that skip Enabled.
+func (sh *SlogHandler) Handle(ctx context.Context, record slog.Record) error {
+	in := FromContext(ctx)
+	level := levelFromSlog(record.Level)
+
+	if !in.enabled(level) {
+		return nil
+	}
+
+	in.countLog(level)
+
+	theseTags := tagsFromContext(ctx)
+	addTraceParent(ctx, theseTags)
+
+	maps.Copy(theseTags, sh.attrs)
+	record.Attrs(func(attr slog.Attr) bool {
+		addSlogAttr(theseTags, sh.prefix, attr)
+
+		return true
+	})
+
+	if record.PC != 0 {
+		frame, _ := runtime.CallersFrames([]uintptr{record.PC}).Next()
+		theseTags["meta.caller"] = frame.Function
+		theseTags["meta.file"] = frame.File
+		theseTags["meta.line"] = frame.Line
+	}
+
+	// A zero time means the record has none, which slog handlers are expected to leave out rather than make up.
+	if record.Time.IsZero() {
+		theseTags["meta.timestamp"] = nil
+	} else {
+		theseTags["meta.timestamp"] = record.Time
+	}
+
+	theseTags["meta.level"] = level
+	theseTags["log.message"] = record.Message
+
+	in.emit(ctx, theseTags)
+
+	return nil
+}
+
+// WithAttrs returns a handler that adds the attributes to every record.
+func (sh *SlogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	next := &SlogHandler{
+		attrs:  maps.Clone(sh.attrs),
+		prefix: sh.prefix,
+	}
+
+	for _, attr := range attrs {
+		addSlogAttr(next.attrs, next.prefix, attr)
+	}
+
+	return next
+}
+
+// WithGroup returns a handler that puts later attributes in the group.
+func (sh *SlogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return sh
+	}
+
+	return &SlogHandler{
+		attrs:  sh.attrs,
+		prefix: sh.prefix + name + ".",
+	}
+}
+
+// addSlogAttr adds an attribute to the tags, flattening groups into dotted keys.
+func addSlogAttr(tags Tags, prefix string, attr slog.Attr) {
+	attr.Value = attr.Value.Resolve()
+
+	if attr.Equal(slog.Attr{}) {
+		return
+	}
+
+	if attr.Value.Kind() != slog.KindGroup {
+		tags[prefix+attr.Key] = attr.Value.Any()
+
+		return
+	}
+
+	// Groups without a key are inlined, and empty groups are left out entirely.
+	if attr.Key != "" {
+		prefix += attr.Key + "."
+	}
+
+	for _, member := range attr.Value.Group() {
+		addSlogAttr(tags, prefix, member)
+	}
+}
+
+// levelFromSlog maps a slog level onto the closest instrument level.
+func levelFromSlog(level slog.Level) Level {
+	switch {
+	case level < slog.LevelDebug:
+		return TRACE
+	case level < slog.LevelInfo:
+		return DEBUG
+	case level < slog.LevelWarn:
+		return INFO
+	case level < slog.LevelError:
+		return WARN
+	default:
+		return ERROR
+	}
+}
+
+// levelToSlog maps an instrument level onto the closest slog level.
+func levelToSlog(level Level) slog.Level {
+	switch level {
+	case TRACE:
+		return slog.LevelDebug - 4
+	case DEBUG:
+		return slog.LevelDebug
+	case WARN:
+		return slog.LevelWarn
+	case ERROR:
+		return slog.LevelError
+	case FATAL:
+		return slog.LevelError + 4
+	default:
+		return slog.LevelInfo
+	}
+}
+
+// slogMessageKeys are the tags that can stand in for a record's message, in order of preference.
+var slogMessageKeys = []string{"log.message", "trace.name", "event.name", "metric.name"}
+
+// SlogSink forwards events to a slog.Handler, for programs that already send their logs through log/slog.
+//
+// Don't use it with a SlogHandler, which would send every event straight back.
+type SlogSink struct {
+	handler slog.Handler
+}
+
+// NewSlogSink wraps a slog.Handler.
+func NewSlogSink(handler slog.Handler) *SlogSink {
+	return &SlogSink{handler: handler}
+}
+
+// Event converts an event to a slog.Record and handles it. The log message, span name, event name or metric name
+// becomes the record's message, and the remaining tags become attributes.
+func (ss *SlogSink) Event(ctx context.Context, givenTags Tags) error {
+	level, ok := givenTags["meta.level"].(Level)
+	if !ok {
+		level = INFO
+	}
+
+	if !ss.handler.Enabled(ctx, levelToSlog(level)) {
+		return nil
+	}
+
+	timestamp, ok := givenTags["meta.timestamp"].(time.Time)
+	if !ok {
+		timestamp = time.Now()
+	}
+
+	skip := map[string]bool{"meta.level": true, "meta.timestamp": true}
+	message := ""
+
+	for _, key := range slogMessageKeys {
+		if msg, ok := givenTags[key].(string); ok {
+			message = msg
+			skip[key] = true
+
+			break
+		}
+	}
+
+	record := slog.NewRecord(timestamp, levelToSlog(level), message, 0)
+
+	for _, key := range OrderedKeys(ctx, givenTags) {
+		if !skip[key] {
+			record.AddAttrs(slog.Any(key, givenTags[key]))
+		}
+	}
+
+	return ss.handler.Handle(ctx, record)
+}
@@ -0,0 +1,164 @@
+package instrument
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+	"testing/slogtest"
+	"time"
+)
+
+// slogResult turns an event back into the nested map that slogtest expects, by undoing the flattening of groups into
+// dotted keys and renaming the built-in keys.
+func slogResult(event Tags) map[string]any {
+	result := map[string]any{}
+
+	for key, val := range event {
+		switch key {
+		case "meta.timestamp":
+			if val != nil {
+				result[slog.TimeKey] = val
+			}
+
+			continue
+		case "meta.level":
+			result[slog.LevelKey] = val.(Level).String()
+
+			continue
+		case "log.message":
+			result[slog.MessageKey] = val
+
+			continue
+		}
+
+		if strings.HasPrefix(key, "meta.") || key == "trace.parent" {
+			continue
+		}
+
+		parts := strings.Split(key, ".")
+		group := result
+
+		for _, part := range parts[:len(parts)-1] {
+			next, ok := group[part].(map[string]any)
+			if !ok {
+				next = map[string]any{}
+				group[part] = next
+			}
+
+			group = next
+		}
+
+		group[parts[len(parts)-1]] = val
+	}
+
+	return result
+}
+
+func TestSlogHandler(t *testing.T) {
+	// slogtest uses context.Background, so records go to the default instance.
+	sink := &memorySink{}
+	if err := UseSink(t.Name(), sink); err != nil {
+		t.Fatalf("could not add sink: %v", err)
+	}
+
+	t.Cleanup(func() { _ = RemoveSink(t.Name()) })
+
+	silent := defaultInstrument.settings.Load().silent
+	Silence(true)
+	t.Cleanup(func() { Silence(silent) })
+
+	err := slogtest.TestHandler(NewSlogHandler(), func() []map[string]any {
+		results := []map[string]any{}
+
+		for _, event := range sink.Events() {
+			if _, ok := event["log.message"]; ok {
+				results = append(results, slogResult(event))
+			}
+		}
+
+		return results
+	})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSlogSink(t *testing.T) {
+	buf := bytes.Buffer{}
+	sink := NewSlogSink(slog.NewJSONHandler(&buf, nil))
+	at := time.Date(2024, 6, 5, 23, 39, 0, 0, time.UTC)
+
+	err := sink.Event(context.Background(), Tags{
+		"meta.timestamp": at,
+		"meta.level":     WARN,
+		"log.message":    "disk is full",
+		"disk.free":      int64(0),
+	})
+	if err != nil {
+		t.Fatalf("could not handle event: %v", err)
+	}
+
+	// Metrics have no message of their own, so their name stands in for it.
+	if err := sink.Event(context.Background(), Tags{"meta.level": METRIC, "metric.name": "disk.writes"}); err != nil {
+		t.Fatalf("could not handle metric: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d records, want 2:\n%s", len(lines), buf.String())
+	}
+
+	record := map[string]any{}
+	if err := json.Unmarshal([]byte(lines[0]), &record); err != nil {
+		t.Fatalf("could not decode record: %v", err)
+	}
+
+	want := map[string]any{
+		"time":      at.Format(time.RFC3339),
+		"level":     "WARN",
+		"msg":       "disk is full",
+		"disk.free": float64(0),
+	}
+
+	for key, val := range want {
+		if record[key] != val {
+			t.Errorf("record has %s=%v, want %v", key, record[key], val)
+		}
+	}
+
+	if strings.Contains(lines[0], "meta.level") || strings.Contains(lines[0], "log.message") {
+		t.Errorf("record repeats the level or message as attributes: %s", lines[0])
+	}
+
+	if !strings.Contains(lines[1], `"msg":"disk.writes"`) {
+		t.Errorf("metric record has no message: %s", lines[1])
+	}
+}
+
+func TestSlogHandlerDropsDisabledRecords(t *testing.T) {
+	in := newTestInstrument(t)
+	sink := &memorySink{}
+
+	if err := in.UseSink("memory", sink); err != nil {
+		t.Fatalf("could not add sink: %v", err)
+	}
+
+	// Calling Handle directly skips Enabled, as some wrapping handlers do.
+	ctx := WithInstrument(context.Background(), in)
+	record := slog.NewRecord(time.Now(), slog.LevelDebug, "hidden", 0)
+
+	if err := NewSlogHandler().Handle(ctx, record); err != nil {
+		t.Fatalf("could not handle record: %v", err)
+	}
+
+	if events := sink.Events(); len(events) != 0 {
+		t.Errorf("got %v for a record below the level", events)
+	}
+
+	if got := in.logsTotal.Value(); got != 0 {
+		t.Errorf("counted %d logs for a record below the level", got)
+	}
+}
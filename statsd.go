@@ -0,0 +1,231 @@
+package instrument
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Defaults for a StatsdConfig left at its zero value.
+const (
+	defaultStatsdMTU      = 1432
+	defaultStatsdInterval = 100 * time.Millisecond
+)
+
+// StatsdConfig controls where a StatsdSink sends metrics.
+type StatsdConfig struct {
+	// Network is "udp" or "unixgram". Empty means "udp".
+	Network string
+
+	// Address is the agent's host:port, or socket path for unixgram.
+	Address string
+
+	// Prefix is prepended to every metric name, separated by a dot.
+	Prefix string
+
+	// DogStatsD adds the tags from the context passed to NewStatsdSink to every metric, and sends the labels of metrics
+	// in a vec as tags. Without it, label values are appended to the metric name instead.
+	DogStatsD bool
+
+	// MTU caps the size of each packet. Zero uses a default of 1432 bytes, which fits in most networks.
+	MTU int
+
+	// Interval is how long metrics wait for a packet to fill up. Zero uses a default of 100ms.
+	Interval time.Duration
+}
+
+// StatsdSink mirrors counters, gauges, and histograms to a statsd agent as they're updated.
+//
+// Metrics are coalesced into packets of up to MTU bytes. StatsdSink only handles metrics, so it ignores events.
+type StatsdSink struct {
+	config StatsdConfig
+	conn   net.Conn
+	prefix string
+	tags   string
+
+	mu     sync.Mutex
+	packet []byte
+	timer  *time.Timer
+	err    error
+}
+
+// NewStatsdSink connects to a statsd agent. With DogStatsD enabled, the tags in ctx are sent with every metric.
+func NewStatsdSink(ctx context.Context, config StatsdConfig) (*StatsdSink, error) {
+	if config.Network == "" {
+		config.Network = "udp"
+	}
+
+	if config.MTU <= 0 {
+		config.MTU = defaultStatsdMTU
+	}
+
+	if config.Interval <= 0 {
+		config.Interval = defaultStatsdInterval
+	}
+
+	conn, err := (&net.Dialer{}).DialContext(ctx, config.Network, config.Address)
+	if err != nil {
+		return nil, fmt.Errorf("could not connect to statsd: %w", err)
+	}
+
+	ss := &StatsdSink{
+		config: config,
+		conn:   conn,
+		prefix: config.Prefix,
+	}
+
+	if ss.prefix != "" && !strings.HasSuffix(ss.prefix, ".") {
+		ss.prefix += "."
+	}
+
+	if config.DogStatsD {
+		ss.tags = dogstatsdTags(tagsFromContext(ctx))
+	}
+
+	return ss, nil
+}
+
+// Event ignores events, since metrics arrive through the MetricSink methods instead.
+func (ss *StatsdSink) Event(context.Context, Tags) error {
+	return nil
+}
+
+// Count sends a counter delta.
+func (ss *StatsdSink) Count(name string, labels Tags, delta uint64) {
+	ss.add(name, labels, strconv.FormatUint(delta, 10), "c")
+}
+
+// Gauge sends a gauge value.
+func (ss *StatsdSink) Gauge(name string, labels Tags, value int64) {
+	// A leading sign makes statsd adjust the gauge rather than set it, so negative values need a reset to zero first.
+	if value < 0 {
+		ss.add(name, labels, "0", "g")
+	}
+
+	ss.add(name, labels, strconv.FormatInt(value, 10), "g")
+}
+
+// Histogram sends a histogram value as a timing.
+func (ss *StatsdSink) Histogram(name string, labels Tags, value int64) {
+	ss.add(name, labels, strconv.FormatInt(value, 10), "ms")
+}
+
+// Flush sends any partially-filled packet, and returns the first error since the last Flush.
+func (ss *StatsdSink) Flush() error {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	ss.send()
+
+	err := ss.err
+	ss.err = nil
+
+	return err
+}
+
+// Close flushes and disconnects from the agent.
+func (ss *StatsdSink) Close() error {
+	return errors.Join(ss.Flush(), ss.conn.Close())
+}
+
+// add appends a metric to the current packet, sending it first if the metric wouldn't fit.
+func (ss *StatsdSink) add(name string, labels Tags, value, kind string) {
+	tags := ss.tags
+
+	switch {
+	case len(labels) == 0:
+	case ss.config.DogStatsD && tags != "":
+		tags += "," + dogstatsdTags(labels)
+	case ss.config.DogStatsD:
+		tags = dogstatsdTags(labels)
+	default:
+		name = statsdLabeledName(name, labels)
+	}
+
+	line := ss.prefix + statsdName(name) + ":" + value + "|" + kind
+	if tags != "" {
+		line += "|#" + tags
+	}
+
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	if len(ss.packet) > 0 && len(ss.packet)+1+len(line) > ss.config.MTU {
+		ss.send()
+	}
+
+	if len(ss.packet) > 0 {
+		ss.packet = append(ss.packet, '\n')
+	}
+
+	ss.packet = append(ss.packet, line...)
+
+	if ss.timer == nil {
+		ss.timer = time.AfterFunc(ss.config.Interval, func() {
+			ss.mu.Lock()
+			defer ss.mu.Unlock()
+
+			ss.send()
+		})
+	}
+}
+
+// send writes out the current packet. The caller must hold the lock.
+func (ss *StatsdSink) send() {
+	if ss.timer != nil {
+		ss.timer.Stop()
+		ss.timer = nil
+	}
+
+	if len(ss.packet) == 0 {
+		return
+	}
+
+	if _, err := ss.conn.Write(ss.packet); err != nil && ss.err == nil {
+		ss.err = fmt.Errorf("could not send to statsd: %w", err)
+	}
+
+	ss.packet = ss.packet[:0]
+}
+
+// statsdName replaces the characters that statsd uses as separators.
+func statsdName(name string) string {
+	return strings.NewReplacer(":", "_", "|", "_", "@", "_", "#", "_", "\n", "_").Replace(name)
+}
+
+// statsdLabeledName appends the label values to a metric name, in the order of their names, as in
+// http.requests.200.
+func statsdLabeledName(name string, labels Tags) string {
+	keys := make([]string, 0, len(labels))
+	for key := range labels {
+		keys = append(keys, key)
+	}
+
+	slices.Sort(keys)
+
+	for _, key := range keys {
+		name += "." + fmt.Sprint(labels[key])
+	}
+
+	return name
+}
+
+// dogstatsdTags formats tags as a sorted, comma-separated list of key:value pairs.
+func dogstatsdTags(tags Tags) string {
+	pairs := make([]string, 0, len(tags))
+	replacer := strings.NewReplacer(",", "_", "|", "_", "#", "_", "\n", "_")
+
+	for key, val := range tags {
+		pairs = append(pairs, replacer.Replace(fmt.Sprintf("%s:%v", key, val)))
+	}
+
+	slices.Sort(pairs)
+
+	return strings.Join(pairs, ",")
+}
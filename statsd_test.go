@@ -0,0 +1,112 @@
+package instrument
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestStatsdSink starts a UDP agent and a sink that only sends packets when they're full or flushed.
+func newTestStatsdSink(t *testing.T, ctx context.Context, config StatsdConfig) (*StatsdSink, net.PacketConn) {
+	t.Helper()
+
+	server, addr := listenUDP(t)
+	config.Address = addr
+	config.Interval = time.Hour
+
+	ss, err := NewStatsdSink(ctx, config)
+	if err != nil {
+		t.Fatalf("could not create sink: %v", err)
+	}
+
+	t.Cleanup(func() { _ = ss.Close() })
+
+	return ss, server
+}
+
+// flushLines flushes the sink and reads one packet, split into its lines.
+func flushLines(t *testing.T, ss *StatsdSink, server net.PacketConn) []string {
+	t.Helper()
+
+	if err := ss.Flush(); err != nil {
+		t.Fatalf("could not flush: %v", err)
+	}
+
+	return strings.Split(readPacket(t, server), "\n")
+}
+
+func TestStatsdSinkCoalescesUpToMTU(t *testing.T) {
+	ss, server := newTestStatsdSink(t, context.Background(), StatsdConfig{MTU: 40})
+
+	// Each line is 8 bytes, so four of them and their separators fit in 40, but not five.
+	for i := 0; i < 10; i++ {
+		ss.Count("hits", nil, 1)
+	}
+
+	if err := ss.Flush(); err != nil {
+		t.Fatalf("could not flush: %v", err)
+	}
+
+	lines := 0
+
+	for _, want := range []int{4, 4, 2} {
+		packet := readPacket(t, server)
+		if len(packet) > 40 {
+			t.Errorf("packet of %d bytes is over the MTU: %q", len(packet), packet)
+		}
+
+		got := strings.Split(packet, "\n")
+		if len(got) != want {
+			t.Errorf("got %d lines in a packet, want %d: %q", len(got), want, packet)
+		}
+
+		lines += len(got)
+	}
+
+	if lines != 10 {
+		t.Errorf("got %d lines in total, want 10", lines)
+	}
+}
+
+func TestStatsdSinkFormats(t *testing.T) {
+	ss, server := newTestStatsdSink(t, context.Background(), StatsdConfig{Prefix: "app"})
+
+	ss.Count("http.requests", Tags{"status": 200, "method": "GET"}, 3)
+	ss.Gauge("queue.depth", nil, -5)
+	ss.Histogram("db:query|latency", nil, 12)
+
+	want := []string{
+		"app.http.requests.GET.200:3|c",
+		"app.queue.depth:0|g",
+		"app.queue.depth:-5|g",
+		"app.db_query_latency:12|ms",
+	}
+
+	if got := flushLines(t, ss, server); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got lines\n%s, want\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestStatsdSinkDogStatsD(t *testing.T) {
+	ctx := WithAll(context.Background(), Tags{"env": "prod", "region": "eu"})
+	ss, server := newTestStatsdSink(t, ctx, StatsdConfig{DogStatsD: true, Prefix: "app."})
+	in := newTestInstrument(t)
+
+	if err := in.UseSink("statsd", ss); err != nil {
+		t.Fatalf("could not add sink: %v", err)
+	}
+
+	in.NewCounterVec("http.requests", "status").With("500").Add()
+	in.NewGauge("workers").Set(4)
+
+	want := []string{
+		"app.http.requests:1|c|#env:prod,region:eu,status:500",
+		"app.workers:4|g|#env:prod,region:eu",
+	}
+
+	if got := flushLines(t, ss, server); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got lines\n%s, want\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
@@ -0,0 +1,345 @@
+package instrument
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SyslogFormat is the syslog message format.
+type SyslogFormat int
+
+const (
+	// RFC5424 is the current syslog format, with structured data.
+	RFC5424 SyslogFormat = iota
+	// RFC3164 is the older BSD syslog format.
+	RFC3164
+)
+
+const (
+	// defaultSyslogTimeout bounds each connection attempt and write.
+	defaultSyslogTimeout = 5 * time.Second
+
+	// syslogUserFacility is the facility for user-level messages.
+	syslogUserFacility = 1
+
+	// syslogMaxHostname and syslogMaxAppName are the longest HOSTNAME and APP-NAME fields that RFC 5424 allows.
+	syslogMaxHostname = 255
+	syslogMaxAppName  = 48
+
+	// syslogSDID names the structured-data element with instrument's metadata. 32473 is the private enterprise number
+	// reserved for documentation and examples.
+	syslogSDID = "instrument@32473"
+)
+
+// Syslog severities.
+const (
+	syslogCritical      = 2
+	syslogError         = 3
+	syslogWarning       = 4
+	syslogInformational = 6
+	syslogDebug         = 7
+)
+
+// SyslogConfig controls where a SyslogSink sends events and how it formats them.
+type SyslogConfig struct {
+	// Network is one of "udp", "tcp", "tls", "unix" or "unixgram". Empty uses "udp".
+	Network string
+
+	// Address is the server's host:port, or socket path for unix and unixgram.
+	Address string
+
+	// TLS configures the connection for the "tls" network.
+	TLS *tls.Config
+
+	// Format is the message format. The zero value is RFC5424.
+	Format SyslogFormat
+
+	// Facility is the syslog facility code. Zero uses the user-level facility (1), since 0 is reserved for the kernel.
+	Facility int
+
+	// AppName identifies the program. Empty uses the name of the running binary. Characters that syslog headers don't
+	// allow are replaced with underscores, and it's cut to 48 characters.
+	AppName string
+
+	// Hostname identifies the machine. Empty uses the system's hostname. Like AppName, it's cleaned up for the header,
+	// and cut to 255 characters.
+	Hostname string
+
+	// JSON sends the whole event as JSON, rather than only the log message. Events without a log message, such as
+	// spans and metrics, are always sent as JSON.
+	JSON bool
+
+	// Timeout bounds each connection attempt and write. Zero uses a default of five seconds.
+	Timeout time.Duration
+}
+
+// SyslogSink sends events to a syslog server.
+//
+// Stream connections use octet-counted framing from RFC 6587. If a write fails, the sink reconnects and tries once
+// more before returning the error. Reconnecting doesn't hold up events from other goroutines.
+type SyslogSink struct {
+	config SyslogConfig
+	pid    string
+
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+// NewSyslogSink connects to a syslog server.
+func NewSyslogSink(config SyslogConfig) (*SyslogSink, error) {
+	if config.Network == "" {
+		config.Network = "udp"
+	}
+
+	if config.Facility == 0 {
+		config.Facility = syslogUserFacility
+	}
+
+	if config.AppName == "" {
+		config.AppName = filepath.Base(os.Args[0])
+	}
+
+	if config.Hostname == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return nil, fmt.Errorf("could not get hostname: %w", err)
+		}
+
+		config.Hostname = hostname
+	}
+
+	config.Hostname = syslogHeaderField(config.Hostname, syslogMaxHostname)
+	config.AppName = syslogHeaderField(config.AppName, syslogMaxAppName)
+
+	if config.Timeout <= 0 {
+		config.Timeout = defaultSyslogTimeout
+	}
+
+	ss := &SyslogSink{
+		config: config,
+		pid:    strconv.Itoa(os.Getpid()),
+	}
+
+	conn, err := ss.dial()
+	if err != nil {
+		return nil, err
+	}
+
+	ss.conn = conn
+
+	return ss, nil
+}
+
+// Event formats the event as a syslog message and sends it.
+func (ss *SyslogSink) Event(ctx context.Context, givenTags Tags) error {
+	msg := ss.format(ctx, givenTags)
+
+	if ss.stream() {
+		msg = append([]byte(strconv.Itoa(len(msg))+" "), msg...)
+	}
+
+	ss.mu.Lock()
+	broken := ss.conn
+	err := ss.write(msg)
+	ss.mu.Unlock()
+
+	if err == nil {
+		return nil
+	}
+
+	// Dial without the lock, so that a dead server only holds up this goroutine.
+	conn, err := ss.dial()
+	if err != nil {
+		return err
+	}
+
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	// Another goroutine may have reconnected in the meantime, in which case its connection is kept.
+	if ss.conn == broken {
+		if ss.conn != nil {
+			_ = ss.conn.Close()
+		}
+
+		ss.conn = conn
+	} else {
+		_ = conn.Close()
+	}
+
+	return ss.write(msg)
+}
+
+// Close disconnects from the server.
+func (ss *SyslogSink) Close() error {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	if ss.conn == nil {
+		return nil
+	}
+
+	err := ss.conn.Close()
+	ss.conn = nil
+
+	return err
+}
+
+// dial opens a new connection to the server.
+func (ss *SyslogSink) dial() (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: ss.config.Timeout}
+
+	var (
+		conn net.Conn
+		err  error
+	)
+
+	if ss.config.Network == "tls" {
+		conn, err = tls.DialWithDialer(dialer, "tcp", ss.config.Address, ss.config.TLS)
+	} else {
+		conn, err = dialer.Dial(ss.config.Network, ss.config.Address)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("could not connect to syslog: %w", err)
+	}
+
+	return conn, nil
+}
+
+// write sends a framed message over the current connection.
+func (ss *SyslogSink) write(msg []byte) error {
+	if ss.conn == nil {
+		return fmt.Errorf("could not send to syslog: not connected")
+	}
+
+	if err := ss.conn.SetWriteDeadline(time.Now().Add(ss.config.Timeout)); err != nil {
+		return fmt.Errorf("could not send to syslog: %w", err)
+	}
+
+	if _, err := ss.conn.Write(msg); err != nil {
+		return fmt.Errorf("could not send to syslog: %w", err)
+	}
+
+	return nil
+}
+
+// stream checks if the connection needs message framing.
+func (ss *SyslogSink) stream() bool {
+	switch ss.config.Network {
+	case "tcp", "tcp4", "tcp6", "tls", "unix":
+		return true
+	default:
+		return false
+	}
+}
+
+// format builds the syslog message for an event.
+func (ss *SyslogSink) format(ctx context.Context, givenTags Tags) []byte {
+	level, ok := givenTags["meta.level"].(Level)
+	if !ok {
+		level = INFO
+	}
+
+	timestamp, ok := givenTags["meta.timestamp"].(time.Time)
+	if !ok {
+		timestamp = time.Now()
+	}
+
+	priority := ss.config.Facility*8 + syslogSeverity(level)
+	buf := bytes.Buffer{}
+
+	if ss.config.Format == RFC3164 {
+		fmt.Fprintf(&buf, "<%d>%s %s %s[%s]: ",
+			priority, timestamp.Local().Format(time.Stamp), ss.config.Hostname, ss.config.AppName, ss.pid)
+	} else {
+		fmt.Fprintf(&buf, "<%d>1 %s %s %s %s - %s ",
+			priority,
+			timestamp.Format("2006-01-02T15:04:05.000000Z07:00"),
+			ss.config.Hostname,
+			ss.config.AppName,
+			ss.pid,
+			syslogStructuredData(givenTags),
+		)
+	}
+
+	if msg, ok := givenTags["log.message"].(string); ok && !ss.config.JSON {
+		buf.WriteString(msg)
+	} else {
+		buf.Write(marshalPlain(ctx, givenTags))
+	}
+
+	return buf.Bytes()
+}
+
+// syslogSeverity maps a level onto the closest syslog severity.
+func syslogSeverity(level Level) int {
+	switch level {
+	case TRACE, DEBUG:
+		return syslogDebug
+	case WARN:
+		return syslogWarning
+	case ERROR:
+		return syslogError
+	case FATAL:
+		return syslogCritical
+	default:
+		return syslogInformational
+	}
+}
+
+// syslogStructuredData puts the caller and instance into an RFC 5424 structured-data element.
+func syslogStructuredData(givenTags Tags) string {
+	params := []string{}
+
+	for _, key := range []string{"meta.caller", "meta.instance"} {
+		if val, ok := givenTags[key]; ok {
+			name := strings.TrimPrefix(key, "meta.")
+			params = append(params, fmt.Sprintf("%s=\"%s\"", name, syslogParamValue(fmt.Sprint(val))))
+		}
+	}
+
+	if len(params) == 0 {
+		return "-"
+	}
+
+	return "[" + syslogSDID + " " + strings.Join(params, " ") + "]"
+}
+
+// syslogParamValue escapes the characters that RFC 5424 doesn't allow in parameter values.
+func syslogParamValue(val string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`).Replace(val)
+}
+
+// syslogHeaderField replaces an empty header field with the nil value, and anything but printable ASCII, such as
+// spaces, with underscores. The result is cut to the given length.
+func syslogHeaderField(val string, maxLen int) string {
+	if val == "" {
+		return "-"
+	}
+
+	field := []byte{}
+
+	for _, r := range val {
+		if len(field) == maxLen {
+			break
+		}
+
+		if r < '!' || r > '~' {
+			r = '_'
+		}
+
+		field = append(field, byte(r))
+	}
+
+	return string(field)
+}
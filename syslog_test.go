@@ -0,0 +1,215 @@
+package instrument
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// listenUDP starts a UDP syslog server, returning it and its address.
+func listenUDP(t *testing.T) (net.PacketConn, string) {
+	t.Helper()
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("could not listen: %v", err)
+	}
+
+	t.Cleanup(func() { _ = conn.Close() })
+
+	return conn, conn.LocalAddr().String()
+}
+
+// readPacket reads one message from a UDP server.
+func readPacket(t *testing.T, conn net.PacketConn) string {
+	t.Helper()
+
+	buf := make([]byte, 64*1024)
+
+	if err := conn.SetReadDeadline(time.Now().Add(5 * time.Second)); err != nil {
+		t.Fatalf("could not set deadline: %v", err)
+	}
+
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatalf("could not read message: %v", err)
+	}
+
+	return string(buf[:n])
+}
+
+// readFrame reads one octet-counted message from a stream.
+func readFrame(reader *bufio.Reader) (string, error) {
+	length, err := reader.ReadString(' ')
+	if err != nil {
+		return "", err
+	}
+
+	size, err := strconv.Atoi(strings.TrimSuffix(length, " "))
+	if err != nil {
+		return "", fmt.Errorf("bad frame length %q: %w", length, err)
+	}
+
+	msg := make([]byte, size)
+	if _, err := io.ReadFull(reader, msg); err != nil {
+		return "", err
+	}
+
+	return string(msg), nil
+}
+
+func newTestSyslogSink(t *testing.T, config SyslogConfig) *SyslogSink {
+	t.Helper()
+
+	ss, err := NewSyslogSink(config)
+	if err != nil {
+		t.Fatalf("could not create sink: %v", err)
+	}
+
+	t.Cleanup(func() { _ = ss.Close() })
+
+	return ss
+}
+
+// syslogEvent is a warning with metadata that needs escaping in structured data.
+var syslogEvent = Tags{
+	"meta.timestamp": time.Date(2024, 6, 5, 23, 39, 0, 0, time.UTC),
+	"meta.level":     WARN,
+	"meta.caller":    `main.run "x"]\y`,
+	"meta.instance":  "abc",
+	"log.message":    "disk is full",
+}
+
+func TestSyslogSinkRFC5424(t *testing.T) {
+	server, addr := listenUDP(t)
+
+	// An empty network defaults to UDP.
+	ss := newTestSyslogSink(t, SyslogConfig{Address: addr, Hostname: "web 1", AppName: "api"})
+
+	if err := ss.Event(context.Background(), syslogEvent); err != nil {
+		t.Fatalf("could not send: %v", err)
+	}
+
+	want := fmt.Sprintf(`<12>1 2024-06-05T23:39:00.000000Z web_1 api %d - `+
+		`[instrument@32473 caller="main.run \"x\"\]\\y" instance="abc"] disk is full`, os.Getpid())
+
+	if got := readPacket(t, server); got != want {
+		t.Errorf("got\n%s, want\n%s", got, want)
+	}
+}
+
+func TestSyslogSinkRFC3164(t *testing.T) {
+	server, addr := listenUDP(t)
+	ss := newTestSyslogSink(t, SyslogConfig{
+		Network:  "udp",
+		Address:  addr,
+		Format:   RFC3164,
+		Facility: 16,
+		Hostname: "web1",
+		AppName:  "api",
+	})
+
+	if err := ss.Event(context.Background(), syslogEvent); err != nil {
+		t.Fatalf("could not send: %v", err)
+	}
+
+	stamp := syslogEvent["meta.timestamp"].(time.Time).Local().Format(time.Stamp)
+	want := fmt.Sprintf("<132>%s web1 api[%d]: disk is full", stamp, os.Getpid())
+
+	if got := readPacket(t, server); got != want {
+		t.Errorf("got\n%s, want\n%s", got, want)
+	}
+}
+
+func TestSyslogHeaderField(t *testing.T) {
+	for _, tc := range []struct {
+		val    string
+		maxLen int
+		want   string
+	}{
+		{"", 48, "-"},
+		{"web 1", 48, "web_1"},
+		{"tab\there\x00", 48, "tab_here_"},
+		{"café", 48, "caf_"},
+		{strings.Repeat("a", 60), 48, strings.Repeat("a", 48)},
+	} {
+		if got := syslogHeaderField(tc.val, tc.maxLen); got != tc.want {
+			t.Errorf("syslogHeaderField(%q) = %q, want %q", tc.val, got, tc.want)
+		}
+	}
+}
+
+func TestSyslogSinkTCPReconnects(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("could not listen: %v", err)
+	}
+
+	t.Cleanup(func() { _ = listener.Close() })
+
+	conns := make(chan net.Conn)
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				close(conns)
+
+				return
+			}
+
+			conns <- conn
+		}
+	}()
+
+	ss := newTestSyslogSink(t, SyslogConfig{Network: "tcp", Address: listener.Addr().String(), JSON: true})
+	first := <-conns
+
+	if err := ss.Event(context.Background(), Tags{"log.message": "one\ntwo", "n": 1}); err != nil {
+		t.Fatalf("could not send: %v", err)
+	}
+
+	// The frame's length covers the whole message, newlines included.
+	msg, err := readFrame(bufio.NewReader(first))
+	if err != nil {
+		t.Fatalf("could not read frame: %v", err)
+	}
+
+	if !strings.HasSuffix(msg, `{"log.message": "one\ntwo", "n": 1}`) {
+		t.Errorf("got message %q", msg)
+	}
+
+	// The server drops the connection. The first writes may still succeed, until the sink notices and reconnects.
+	_ = first.Close()
+
+	deadline := time.After(10 * time.Second)
+
+	for i := 0; ; i++ {
+		_ = ss.Event(context.Background(), Tags{"log.message": "after", "n": i})
+
+		select {
+		case second := <-conns:
+			defer second.Close()
+
+			if err := second.SetReadDeadline(time.Now().Add(5 * time.Second)); err != nil {
+				t.Fatalf("could not set deadline: %v", err)
+			}
+
+			if _, err := readFrame(bufio.NewReader(second)); err != nil {
+				t.Fatalf("could not read from the new connection: %v", err)
+			}
+
+			return
+		case <-deadline:
+			t.Fatal("sink didn't reconnect after the server dropped the connection")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
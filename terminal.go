@@ -1,41 +1,127 @@
 package instrument
 
 import (
+	"bytes"
 	"context"
 	"fmt"
+	"maps"
 	"os"
+	"sync"
+	"sync/atomic"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
 )
 
-// TerminalSink emits events to the terminal with optional colors.
-type TerminalSink struct{}
+// TerminalFormat chooses how the terminal sink lays out events.
+type TerminalFormat int32
+
+const (
+	// TerminalAuto uses the console layout when stderr is a terminal, and JSON otherwise.
+	TerminalAuto TerminalFormat = iota
+
+	// TerminalJSON always writes JSON.
+	TerminalJSON
+
+	// TerminalConsole always uses the console layout.
+	TerminalConsole
+)
 
-// Event writes colorized JSON to the terminal for debugging.
-func (cs *TerminalSink) Event(_ context.Context, givenTags Tags) error {
-	if *silent {
-		return nil
+// String returns the format's name.
+func (tf TerminalFormat) String() string {
+	switch tf {
+	case TerminalAuto:
+		return "auto"
+	case TerminalJSON:
+		return "json"
+	case TerminalConsole:
+		return "console"
+	default:
+		return fmt.Sprintf("TerminalFormat(%d)", int32(tf))
 	}
+}
 
-	keyColor := levelToColor[INFO]
+// TerminalSink emits events to stderr with optional colors, in the console layout or as JSON.
+//
+// Like a WriterSink, it has its own renderer, so its colors are detected from stderr rather than from lipgloss'
+// default renderer, which looks at stdout.
+type TerminalSink struct {
+	format   atomic.Int32
+	out      *os.File
+	renderer *lipgloss.Renderer
 
-	if rawLevel, ok := givenTags["meta.level"]; ok {
-		level, ok := rawLevel.(Level)
-		if ok {
-			keyColor = levelToColor[level]
+	// isTerminal checks once whether the output is a terminal rather than a file or a pipe.
+	isTerminal func() bool
+}
 
-			// We really don't care about seeing the "MET" meta.level, so let's remove it from the output.
-			if level == METRIC {
-				delete(givenTags, "meta.level")
-			}
-		}
+// newTerminalSink returns a terminal sink that writes to the given file.
+func newTerminalSink(out *os.File) *TerminalSink {
+	return &TerminalSink{
+		out:      out,
+		renderer: lipgloss.NewRenderer(out),
+		isTerminal: sync.OnceValue(func() bool {
+			info, err := out.Stat()
+
+			return err == nil && info.Mode()&os.ModeCharDevice != 0
+		}),
 	}
+}
 
-	final := marshal(givenTags, keyColor)
-	if _, err := os.Stderr.Write(final); err != nil {
-		return fmt.Errorf("could not emit log: %w", err)
+// Renderer returns the renderer used to color events. Its color profile can be changed to force colors on or off.
+func (cs *TerminalSink) Renderer() *lipgloss.Renderer {
+	return cs.renderer
+}
+
+// SetColor forces colors on or off.
+func (cs *TerminalSink) SetColor(to bool) {
+	if to {
+		cs.renderer.SetColorProfile(termenv.TrueColor)
+	} else {
+		cs.renderer.SetColorProfile(termenv.Ascii)
 	}
+}
 
-	if _, err := os.Stderr.WriteString("\n"); err != nil {
-		return fmt.Errorf("could not add newline: %w", err)
+// ResetColor goes back to the colors detected from the sink's output.
+func (cs *TerminalSink) ResetColor() {
+	cs.renderer.SetColorProfile(termenv.NewOutput(cs.out).EnvColorProfile())
+}
+
+// SetFormat changes how the sink lays out events. It's safe to call while events are being emitted.
+func (cs *TerminalSink) SetFormat(format TerminalFormat) {
+	cs.format.Store(int32(format))
+}
+
+// Format returns how the sink lays out events.
+func (cs *TerminalSink) Format() TerminalFormat {
+	return TerminalFormat(cs.format.Load())
+}
+
+// Event writes an event to the terminal for debugging.
+func (cs *TerminalSink) Event(ctx context.Context, givenTags Tags) error {
+	buf := bytes.Buffer{}
+	format := cs.Format()
+
+	if format == TerminalConsole || (format == TerminalAuto && cs.isTerminal()) {
+		if err := (ConsoleEncoder{}).Encode(ctx, &buf, givenTags, cs.renderer); err != nil {
+			return fmt.Errorf("could not encode log: %w", err)
+		}
+	} else {
+		colors := colorPalette(levelStyle(givenTags)).on(cs.renderer)
+
+		// We really don't care about seeing the "MET" meta.level, so let's remove it from the output.
+		// The tags are shared with every other sink, so this has to happen on a copy.
+		if givenTags["meta.level"] == METRIC {
+			givenTags = maps.Clone(givenTags)
+			delete(givenTags, "meta.level")
+		}
+
+		marshalEvent(ctx, givenTags, &buf, colors)
+	}
+
+	buf.WriteByte('\n')
+
+	if _, err := cs.out.Write(buf.Bytes()); err != nil {
+		return fmt.Errorf("could not emit log: %w", err)
 	}
 
 	return nil
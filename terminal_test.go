@@ -0,0 +1,89 @@
+package instrument
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// newFileTerminal returns a terminal sink that writes to a file instead of stderr, and a function to read it back.
+func newFileTerminal(t *testing.T) (*TerminalSink, func() string) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "stderr")
+
+	out, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("could not create %s: %v", path, err)
+	}
+
+	t.Cleanup(func() { _ = out.Close() })
+
+	return newTerminalSink(out), func() string {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("could not read %s: %v", path, err)
+		}
+
+		return string(data)
+	}
+}
+
+func TestTerminalSinkColors(t *testing.T) {
+	sink, read := newFileTerminal(t)
+	event := Tags{"meta.level": ERROR, "log.message": "colored"}
+
+	// A file isn't a terminal, so there are no colors until they're forced on.
+	if err := sink.Event(context.Background(), event); err != nil {
+		t.Fatalf("could not emit: %v", err)
+	}
+
+	if out := read(); strings.Contains(out, "\x1b[") {
+		t.Errorf("got colors in a file: %q", out)
+	}
+
+	global := lipgloss.ColorProfile()
+
+	sink.SetColor(true)
+
+	if err := sink.Event(context.Background(), event); err != nil {
+		t.Fatalf("could not emit: %v", err)
+	}
+
+	if out := read(); !strings.Contains(out, "\x1b[") {
+		t.Errorf("got no colors after forcing them on: %q", out)
+	}
+
+	if got := lipgloss.ColorProfile(); got != global {
+		t.Errorf("forcing colors changed lipgloss' global profile from %v to %v", global, got)
+	}
+}
+
+func TestTerminalSinkAutoFormat(t *testing.T) {
+	sink, read := newFileTerminal(t)
+
+	// Stderr is a file here, so the layout and the colors should both be for a file, whatever stdout is.
+	if err := sink.Event(context.Background(), Tags{"meta.level": INFO, "log.message": "to a file"}); err != nil {
+		t.Fatalf("could not emit: %v", err)
+	}
+
+	out := read()
+	if !json.Valid([]byte(out)) || strings.Contains(out, "\x1b[") {
+		t.Errorf("got %q, want plain JSON", out)
+	}
+
+	sink.SetFormat(TerminalConsole)
+
+	if err := sink.Event(context.Background(), Tags{"meta.level": INFO, "log.message": "console"}); err != nil {
+		t.Fatalf("could not emit: %v", err)
+	}
+
+	if lines := strings.Split(strings.TrimSpace(read()), "\n"); len(lines) != 2 || lines[1] != "INF console" {
+		t.Errorf("got %q, want a console line", lines)
+	}
+}
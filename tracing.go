@@ -8,18 +8,24 @@ import (
 	"github.com/google/uuid"
 )
 
-const traceCallerSkip = 2
-
-var (
-	tracesTotal  Counter = "instrument.traces.total"
-	tracesErrors Counter = "instrument.traces.errors"
-)
+const traceCallerSkip = 3
 
 // TraceFunc implementers run in the context of a trace.
 type TraceFunc func(ctx context.Context, addToParent func(Tags)) error
 
 // WithSpan runs a given function and emits trace-specific metadata.
 func WithSpan(ctx context.Context, name string, traced TraceFunc) error {
+	return FromContext(ctx).withSpan(ctx, name, traced)
+}
+
+// WithSpan runs a given function and emits trace-specific metadata to the instance. The function's context is bound
+// to the instance, so that package-level calls inside the span use it too.
+func (in *Instrument) WithSpan(ctx context.Context, name string, traced TraceFunc) error {
+	return in.withSpan(ctx, name, traced)
+}
+
+// withSpan runs a span for WithSpan.
+func (in *Instrument) withSpan(ctx context.Context, name string, traced TraceFunc) error {
 	caller, filename, line := getCaller(traceCallerSkip)
 
 	traceID, err := uuid.NewV7()
@@ -27,17 +33,28 @@ func WithSpan(ctx context.Context, name string, traced TraceFunc) error {
 		return fmt.Errorf("cannot generate a new trace ID: %w", err)
 	}
 
-	parent := traceIDFromContext(ctx)
+	// The outermost span identifies the whole trace, for exporters that need it.
+	root := traceRootFromContext(ctx)
+	if root == uuid.Nil {
+		root = traceID
+	}
+
+	if FromContext(ctx) != in {
+		ctx = WithInstrument(ctx, in)
+	}
+
 	newCtx := context.WithValue(ctx, keyTraceID, traceID)
+	newCtx = context.WithValue(newCtx, keyTraceRoot, root)
+	newCtx = context.WithValue(newCtx, keyTraceDepth, traceDepthFromContext(ctx)+1)
 	start := time.Now()
 	wrappedErr := traced(newCtx, func(ts Tags) {
 		newCtx = WithAll(newCtx, ts)
 	})
 
-	tracesTotal.Add()
+	in.tracesTotal.Add()
 	newTags := tagsFromContext(newCtx)
 	if wrappedErr != nil {
-		tracesErrors.Add()
+		in.tracesErrors.Add()
 
 		newTags["meta.level"] = ERROR
 		newTags["trace.error"] = wrappedErr
@@ -53,11 +70,10 @@ func WithSpan(ctx context.Context, name string, traced TraceFunc) error {
 	newTags["meta.caller"] = caller
 	newTags["trace.id"] = traceID
 
-	if parent != uuid.Nil {
-		newTags["trace.parent"] = parent
-	}
+	// The span sits beside the events of its parent, so it gets the parent's tags from the outer context.
+	addTraceParent(ctx, newTags)
 
-	emit(newCtx, newTags)
+	in.emit(newCtx, newTags)
 
 	return wrappedErr
 }
@@ -0,0 +1,309 @@
+package instrument
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// DefaultLabelLimit is how many label sets a vec tracks before it groups the rest together.
+const DefaultLabelLimit = 1000
+
+// OverflowLabel is the value of every label on the child that collects updates past a vec's limit.
+const OverflowLabel = "overflow"
+
+// metricVec is the part of every vec that maps label values onto child metrics.
+type metricVec[T any] struct {
+	in         *Instrument
+	name       string
+	labelNames []string
+	create     func(labels Tags) T
+
+	mu       sync.RWMutex
+	limit    int
+	children map[string]T
+	full     bool
+	overflow *T
+}
+
+func newMetricVec[T any](
+	in *Instrument, name string, labelNames []string, create func(labels Tags) T,
+) *metricVec[T] {
+	return &metricVec[T]{
+		in:         in,
+		name:       name,
+		labelNames: slices.Clone(labelNames),
+		create:     create,
+		limit:      DefaultLabelLimit,
+		children:   map[string]T{},
+	}
+}
+
+// with returns the child for the label values, creating it if there's room.
+func (mv *metricVec[T]) with(values []string) T {
+	if len(values) != len(mv.labelNames) {
+		panic(fmt.Sprintf("%s has %d labels, but got %d values", mv.name, len(mv.labelNames), len(values)))
+	}
+
+	key := strings.Join(values, "\xff")
+
+	mv.mu.RLock()
+	child, ok := mv.children[key]
+	full := mv.full
+	mv.mu.RUnlock()
+
+	if ok {
+		return child
+	}
+
+	if !full {
+		mv.mu.Lock()
+
+		if child, ok := mv.children[key]; ok {
+			mv.mu.Unlock()
+
+			return child
+		}
+
+		if len(mv.children) < mv.limit {
+			child = mv.create(mv.labels(values))
+			mv.children[key] = child
+			mv.mu.Unlock()
+
+			return child
+		}
+
+		mv.full = true
+		first := mv.overflow == nil
+
+		if first {
+			overflowValues := make([]string, len(mv.labelNames))
+			for i := range overflowValues {
+				overflowValues[i] = OverflowLabel
+			}
+
+			overflow := mv.create(mv.labels(overflowValues))
+			mv.overflow = &overflow
+		}
+
+		overflow := *mv.overflow
+		mv.mu.Unlock()
+
+		// Sinks run outside the lock, since they may well update this vec themselves.
+		if first {
+			ctx := WithInstrument(context.Background(), mv.in)
+			mv.in.Warnf(ctx, "Metric %s has more than %d label sets, so new ones are counted together as %q",
+				mv.name, mv.limit, OverflowLabel)
+		}
+
+		mv.in.labelOverflows.Add()
+
+		return overflow
+	}
+
+	mv.mu.RLock()
+	overflow := *mv.overflow
+	mv.mu.RUnlock()
+
+	mv.in.labelOverflows.Add()
+
+	return overflow
+}
+
+// withTags returns the child for the values of the label names in ctx's tags. Missing tags are empty.
+func (mv *metricVec[T]) withTags(ctx context.Context) T {
+	theseTags := tagsFromContext(ctx)
+	values := make([]string, len(mv.labelNames))
+
+	for i, name := range mv.labelNames {
+		if val, ok := theseTags[name]; ok {
+			values[i] = fmt.Sprint(val)
+		}
+	}
+
+	return mv.with(values)
+}
+
+// labels pairs the label names with their values.
+func (mv *metricVec[T]) labels(values []string) Tags {
+	labels := make(Tags, len(values))
+
+	for i, name := range mv.labelNames {
+		labels[name] = values[i]
+	}
+
+	return labels
+}
+
+// setLimit changes how many label sets are tracked. It doesn't drop any that already are.
+func (mv *metricVec[T]) setLimit(limit int) {
+	mv.mu.Lock()
+	defer mv.mu.Unlock()
+
+	mv.limit = limit
+	mv.full = len(mv.children) >= limit && mv.overflow != nil
+}
+
+// each calls the function for every child, including the overflow child if there is one.
+func (mv *metricVec[T]) each(fn func(T)) {
+	mv.mu.RLock()
+	children := make([]T, 0, len(mv.children)+1)
+
+	for _, child := range mv.children {
+		children = append(children, child)
+	}
+
+	if mv.overflow != nil {
+		children = append(children, *mv.overflow)
+	}
+	mv.mu.RUnlock()
+
+	for _, child := range children {
+		fn(child)
+	}
+}
+
+// A CounterVec is a family of counters with the same name, told apart by the values of a fixed set of labels.
+//
+// Use a vec instead of putting dimensions in metric names (e.g., a "status" label rather than http.requests.200).
+// Each vec tracks up to DefaultLabelLimit label sets, after which new ones share a single child whose labels are all
+// OverflowLabel.
+type CounterVec struct {
+	vec *metricVec[*CounterHandle]
+}
+
+// NewCounterVec registers a counter family with the given label names. It panics if the name is already taken.
+func NewCounterVec(name string, labelNames ...string) *CounterVec {
+	return defaultInstrument.NewCounterVec(name, labelNames...)
+}
+
+// NewCounterVec registers a counter family with the instance.
+func (in *Instrument) NewCounterVec(name string, labelNames ...string) *CounterVec {
+	cv := &CounterVec{vec: newMetricVec(in, name, labelNames, func(labels Tags) *CounterHandle {
+		return &CounterHandle{in: in, name: name, labels: labels}
+	})}
+
+	if _, loaded := in.counterVecs.LoadOrStore(name, cv); loaded {
+		panic(name + " already exists")
+	}
+
+	return cv
+}
+
+// With returns the counter for the label values, given in the same order as the label names.
+func (cv *CounterVec) With(values ...string) *CounterHandle {
+	return cv.vec.with(values)
+}
+
+// WithContext returns the counter for the values of the tags in ctx with the same names as the labels.
+func (cv *CounterVec) WithContext(ctx context.Context) *CounterHandle {
+	return cv.vec.withTags(ctx)
+}
+
+// SetLimit changes how many label sets the vec tracks before overflowing.
+func (cv *CounterVec) SetLimit(limit int) {
+	cv.vec.setLimit(limit)
+}
+
+// A GaugeVec is a family of gauges with the same name, told apart by the values of a fixed set of labels. It has the
+// same limit as CounterVec.
+type GaugeVec struct {
+	vec *metricVec[*GaugeHandle]
+}
+
+// NewGaugeVec registers a gauge family with the given label names. It panics if the name is already taken.
+func NewGaugeVec(name string, labelNames ...string) *GaugeVec {
+	return defaultInstrument.NewGaugeVec(name, labelNames...)
+}
+
+// NewGaugeVec registers a gauge family with the instance.
+func (in *Instrument) NewGaugeVec(name string, labelNames ...string) *GaugeVec {
+	gv := &GaugeVec{vec: newMetricVec(in, name, labelNames, func(labels Tags) *GaugeHandle {
+		return &GaugeHandle{in: in, name: name, labels: labels}
+	})}
+
+	if _, loaded := in.gaugeVecs.LoadOrStore(name, gv); loaded {
+		panic(name + " already exists")
+	}
+
+	return gv
+}
+
+// With returns the gauge for the label values, given in the same order as the label names.
+func (gv *GaugeVec) With(values ...string) *GaugeHandle {
+	return gv.vec.with(values)
+}
+
+// WithContext returns the gauge for the values of the tags in ctx with the same names as the labels.
+func (gv *GaugeVec) WithContext(ctx context.Context) *GaugeHandle {
+	return gv.vec.withTags(ctx)
+}
+
+// SetLimit changes how many label sets the vec tracks before overflowing.
+func (gv *GaugeVec) SetLimit(limit int) {
+	gv.vec.setLimit(limit)
+}
+
+// A HistogramVec is a family of histograms with the same name and range, told apart by the values of a fixed set of
+// labels. It has the same limit as CounterVec.
+type HistogramVec struct {
+	vec *metricVec[*Histogram]
+}
+
+// NewHistogramVec registers a histogram family with the given label names. Each child is a windowed histogram, as
+// from NewHistogram. It panics if the name is already taken.
+func NewHistogramVec(name string, minValue, maxValue int64, sigfigs int, labelNames ...string) *HistogramVec {
+	return defaultInstrument.NewHistogramVec(name, minValue, maxValue, sigfigs, labelNames...)
+}
+
+// NewHistogramVec registers a histogram family with the instance.
+func (in *Instrument) NewHistogramVec(
+	name string, minValue, maxValue int64, sigfigs int, labelNames ...string,
+) *HistogramVec {
+	hv := &HistogramVec{vec: newMetricVec(in, name, labelNames, func(labels Tags) *Histogram {
+		hist := in.newHistogram(name, minValue, maxValue, sigfigs)
+		hist.labels = labels
+
+		return hist
+	})}
+
+	if _, loaded := in.histogramVecs.LoadOrStore(name, hv); loaded {
+		panic(name + " already exists")
+	}
+
+	return hv
+}
+
+// With returns the histogram for the label values, given in the same order as the label names.
+func (hv *HistogramVec) With(values ...string) *Histogram {
+	return hv.vec.with(values)
+}
+
+// WithContext returns the histogram for the values of the tags in ctx with the same names as the labels.
+func (hv *HistogramVec) WithContext(ctx context.Context) *Histogram {
+	return hv.vec.withTags(ctx)
+}
+
+// SetLimit changes how many label sets the vec tracks before overflowing.
+func (hv *HistogramVec) SetLimit(limit int) {
+	hv.vec.setLimit(limit)
+}
+
+// labelKey orders label sets for exporters.
+func labelKey(labels Tags) string {
+	keys := make([]string, 0, len(labels))
+	for key := range labels {
+		keys = append(keys, key)
+	}
+
+	slices.Sort(keys)
+
+	pairs := make([]string, len(keys))
+	for i, key := range keys {
+		pairs[i] = fmt.Sprintf("%s\xfe%v", key, labels[key])
+	}
+
+	return strings.Join(pairs, "\xff")
+}
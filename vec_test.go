@@ -0,0 +1,96 @@
+package instrument
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+// reentrantSink updates a vec whenever it sees a log, the way a sink counting its own events might.
+type reentrantSink struct {
+	vec *CounterVec
+}
+
+func (rs *reentrantSink) Event(_ context.Context, givenTags Tags) error {
+	if _, ok := givenTags["log.message"]; ok {
+		rs.vec.With("from-sink").Add()
+	}
+
+	return nil
+}
+
+func TestCounterVecOverflow(t *testing.T) {
+	in := newTestInstrument(t)
+	vec := in.NewCounterVec("test.requests", "path")
+	vec.SetLimit(2)
+
+	vec.With("/a").Add()
+	vec.With("/b").Add()
+	vec.With("/c").Add()
+	vec.With("/d").AddN(2)
+
+	if got := vec.With("/a").Value(); got != 1 {
+		t.Errorf("/a is %d, want 1", got)
+	}
+
+	overflow := vec.With("/e")
+	if overflow.labels["path"] != OverflowLabel {
+		t.Fatalf("got labels %v past the limit, want the overflow child", overflow.labels)
+	}
+
+	if got := overflow.Value(); got != 3 {
+		t.Errorf("overflow is %d, want 3", got)
+	}
+
+	if got := in.labelOverflows.Value(); got != 3 {
+		t.Errorf("instrument.metrics.overflows is %d, want 3", got)
+	}
+}
+
+func TestVecOverflowWarningCanUpdateVec(t *testing.T) {
+	in := newTestInstrument(t)
+	vec := in.NewCounterVec("test.reentrant", "source")
+	vec.SetLimit(1)
+
+	if err := in.UseSink("reentrant", &reentrantSink{vec: vec}); err != nil {
+		t.Fatalf("could not add sink: %v", err)
+	}
+
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		vec.With("first").Add()
+		vec.With("second").Add()
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("a sink updating the vec during the overflow warning deadlocked")
+	}
+
+	total := uint64(0)
+	vec.vec.each(func(child *CounterHandle) {
+		total += child.Value()
+	})
+
+	if total != 3 {
+		t.Errorf("vec has %d updates, want 3", total)
+	}
+}
+
+func TestVecWithWrongValueCount(t *testing.T) {
+	in := newTestInstrument(t)
+	vec := in.NewGaugeVec("test.gauges", "a", "b")
+
+	defer func() {
+		if msg, _ := recover().(string); !strings.Contains(msg, "2 labels") {
+			t.Errorf("got panic %q, want one about the label count", msg)
+		}
+	}()
+
+	vec.With("only one")
+}